@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// apiBaseURL returns the configured API URL, falling back to the default.
+func apiBaseURL() string {
+	if u := viper.GetString("api_url"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return defaultAPIURL
+}
+
+// apiRequest performs a JSON request against the Sapliy API for endpoints the
+// SDK does not cover. It authenticates with the session token and reports
+// errors the same way the SDK does, so callers can treat both uniformly.
+func apiRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal body: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiBaseURL()+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hc, err := sessionHTTPClient()
+	if err != nil {
+		return err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(resp.Body)
+		return &apiError{Status: resp.StatusCode, Body: string(b)}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var authCmd = &cobra.Command{
@@ -14,28 +22,359 @@ var authCmd = &cobra.Command{
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
-	Short: "Log in with your API key",
-	Run: func(cmd *cobra.Command, args []string) {
-		var apiKey string
-		fmt.Print("Enter API Key: ")
-		fmt.Scanln(&apiKey)
-
-		viper.Set("api_key", apiKey)
-		err := viper.WriteConfig()
+	Short: "Log in through your browser or with an API key",
+	Long: `Log in to Sapliy.
+
+On an interactive terminal this starts a device login: the CLI prints a URL
+and a one-time code, opens your browser, and waits for you to approve.
+
+For CI and scripts, pass --api-key ('-' reads it from stdin), or pipe the
+key on stdin:
+  sapliy auth login --api-key sk_test_...
+  echo "$SAPLIY_KEY" | sapliy auth login --api-key -`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		registerSecret(apiKey)
+		noBrowser, _ := cmd.Flags().GetBool("no-browser")
+
+		if apiKey == "-" || (apiKey == "" && !term.IsTerminal(int(os.Stdin.Fd()))) {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read API key from stdin: %w", err)
+			}
+			apiKey = strings.TrimSpace(line)
+			if apiKey == "" {
+				return validationError("no API key provided on stdin")
+			}
+		}
+
+		var cred *credential
+		if apiKey != "" {
+			cred = &credential{APIKey: apiKey}
+		} else {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			token, err := deviceLogin(ctx, !noBrowser)
+			if err != nil {
+				return fmt.Errorf("login failed: %w", err)
+			}
+			cred = credentialFromToken(token)
+		}
+
+		doc, path, err := loadConfigDoc()
 		if err != nil {
-			err = viper.SafeWriteConfig()
+			return fmt.Errorf("read config: %w", err)
 		}
 
+		migrated, err := migratePlaintextKeys(doc)
 		if err != nil {
-			fmt.Printf("Error saving config: %v\n", err)
-			return
+			return fmt.Errorf("migrate plaintext API keys: %w", err)
+		}
+		for _, source := range migrated {
+			fmt.Fprintf(progress(), "Moved plaintext API key from %s into the credential store\n", source)
+		}
+
+		if err := storeProfileCredential(doc, activeProfileName(), cred); err != nil {
+			return fmt.Errorf("save credentials: %w", err)
+		}
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+
+		fmt.Fprintf(progress(), "Successfully authenticated! (profile: %s)\n", activeProfileName())
+		return nil
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the organization and key you are authenticated as",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
+		}
+
+		var me whoamiResponse
+		if err := apiRequest(context.Background(), http.MethodGet, "/v1/auth/whoami", nil, &me); err != nil {
+			return err
+		}
+
+		keyType := me.KeyType
+		if keyType == "" {
+			keyType = keyTypeFromPrefix(apiKey)
+		}
+
+		org := me.OrgID
+		if me.OrgName != "" {
+			org = fmt.Sprintf("%s (%s)", me.OrgName, me.OrgID)
 		}
+		user := me.UserEmail
+		if user == "" {
+			user = me.UserID
+		}
+
+		me.KeyType = keyType
+		me.Profile = activeProfileName()
 
-		fmt.Println("Successfully authenticated!")
+		return printObject(me, func() {
+			fmt.Fprintf(stdout, "Organization: %s\n", valueOrDash(org))
+			fmt.Fprintf(stdout, "User:         %s\n", valueOrDash(user))
+			fmt.Fprintf(stdout, "Key name:     %s\n", valueOrDash(me.KeyName))
+			fmt.Fprintf(stdout, "Key type:     %s\n", valueOrDash(keyType))
+			fmt.Fprintf(stdout, "Scopes:       %s\n", valueOrDash(strings.Join(me.Scopes, ", ")))
+			if me.ExpiresAt != nil {
+				fmt.Fprintf(stdout, "Expires:      %s\n", me.ExpiresAt.Local().Format(time.RFC1123))
+			} else {
+				fmt.Fprintln(stdout, "Expires:      never")
+			}
+			fmt.Fprintf(stdout, "Profile:      %s\n", me.Profile)
+		})
 	},
 }
 
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show where the current credentials and settings come from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileOrigin := settingOrigin("profile")
+		if profileOrigin == "unset" {
+			profileOrigin = "default"
+			if viper.InConfig("active_profile") {
+				profileOrigin = "config file"
+			}
+		}
+
+		st := authStatus{
+			Profile: configSetting{Key: "profile", Value: activeProfileName(), Origin: profileOrigin},
+			APIURL:  configSetting{Key: "api_url", Value: apiBaseURL(), Origin: settingOrigin("api_url")},
+			OrgID:   configSetting{Key: "org_id", Value: viper.GetString("org_id"), Origin: settingOrigin("org_id")},
+			Zone:    configSetting{Key: "current_zone", Value: viper.GetString("current_zone"), Origin: settingOrigin("current_zone")},
+		}
+
+		apiKey, source, err := resolveCredential()
+		if err != nil {
+			return fmt.Errorf("credential: %w", err)
+		}
+		if apiKey != "" {
+			st.LoggedIn = true
+			st.Credential = source
+			st.CredentialType = "api_key"
+			st.KeyMode = keyTypeFromPrefix(apiKey)
+
+			if id := storedCredentialID(); id != "" {
+				c, err := storedCredential(id)
+				if err != nil {
+					return err
+				}
+				if c.APIKey == "" {
+					st.CredentialType = "oauth"
+					st.KeyMode = ""
+					if !c.ExpiresAt.IsZero() {
+						st.TokenExpiresAt = &c.ExpiresAt
+						st.TokenExpired = time.Now().After(c.ExpiresAt)
+					}
+				}
+			}
+		}
+
+		return printObject(st, func() {
+			fmt.Fprintf(stdout, "Profile:     %s (%s)\n", st.Profile.Value, st.Profile.Origin)
+			fmt.Fprintf(stdout, "API URL:     %s (%s)\n", st.APIURL.Value, st.APIURL.Origin)
+			fmt.Fprintf(stdout, "Org ID:      %s (%s)\n", valueOrDash(st.OrgID.Value), st.OrgID.Origin)
+			fmt.Fprintf(stdout, "Zone:        %s (%s)\n", valueOrDash(st.Zone.Value), st.Zone.Origin)
+
+			if !st.LoggedIn {
+				fmt.Fprintln(stdout, "Credential:  not logged in (use 'sapliy auth login')")
+				return
+			}
+			fmt.Fprintf(stdout, "Credential:  %s\n", st.Credential)
+
+			if st.CredentialType == "api_key" {
+				fmt.Fprintf(stdout, "Type:        API key (%s)\n", st.KeyMode)
+				return
+			}
+			fmt.Fprintln(stdout, "Type:        browser login (OAuth)")
+			if st.TokenExpiresAt != nil {
+				state := "valid"
+				if st.TokenExpired {
+					state = "expired"
+				}
+				fmt.Fprintf(stdout, "Token:       %s until %s\n", state, st.TokenExpiresAt.Local().Format(time.RFC1123))
+			}
+		})
+	},
+}
+
+// authStatus is what 'sapliy auth status' reports.
+type authStatus struct {
+	Profile        configSetting `json:"profile"`
+	APIURL         configSetting `json:"api_url"`
+	OrgID          configSetting `json:"org_id"`
+	Zone           configSetting `json:"current_zone"`
+	LoggedIn       bool          `json:"logged_in"`
+	Credential     string        `json:"credential,omitempty"`
+	CredentialType string        `json:"credential_type,omitempty"`
+	KeyMode        string        `json:"key_mode,omitempty"`
+	TokenExpiresAt *time.Time    `json:"token_expires_at,omitempty"`
+	TokenExpired   bool          `json:"token_expired,omitempty"`
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Revoke the current session and remove it from this machine",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+
+		// The credential reference lives either in the active profile or at
+		// the top level of the config file.
+		prefix := fmt.Sprintf("profiles.%s.", activeProfileName())
+		if _, ok := doc.get(prefix + "credential"); !ok {
+			if _, ok := doc.get(prefix + "api_key"); !ok {
+				prefix = ""
+			}
+		}
+
+		removed := false
+		if raw, ok := doc.get(prefix + "credential"); ok {
+			id := fmt.Sprint(raw)
+			store, err := openCredentialStore()
+			if err != nil {
+				return err
+			}
+			if c, ok := store.get(id); ok {
+				revokeCredential(c)
+				store.remove(id)
+				if err := store.save(); err != nil {
+					return fmt.Errorf("save credentials: %w", err)
+				}
+			}
+			doc.unset(prefix + "credential")
+			removed = true
+		}
+		if doc.unset(prefix + "api_key") {
+			removed = true
+		}
+
+		if removed {
+			if err := doc.save(path); err != nil {
+				return fmt.Errorf("save config: %w", err)
+			}
+			fmt.Fprintf(stdout, "Logged out (profile: %s)\n", activeProfileName())
+		} else {
+			fmt.Fprintf(stdout, "Not logged in (profile: %s)\n", activeProfileName())
+		}
+
+		if os.Getenv("SAPLIY_API_KEY") != "" {
+			fmt.Fprintln(stdout, "Note: SAPLIY_API_KEY is still set in your environment.")
+		}
+		return nil
+	},
+}
+
+// whoamiResponse describes the identity behind the current credential.
+type whoamiResponse struct {
+	OrgID     string     `json:"org_id"`
+	OrgName   string     `json:"org_name"`
+	UserID    string     `json:"user_id"`
+	UserEmail string     `json:"user_email"`
+	KeyName   string     `json:"key_name"`
+	KeyType   string     `json:"key_type"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+
+	// Profile is filled in locally for output.
+	Profile string `json:"profile"`
+}
+
+// revokeCredential invalidates OAuth tokens server-side. API keys cannot be
+// revoked from the CLI, so they are only forgotten locally.
+func revokeCredential(c *credential) {
+	if c.APIKey != "" || (c.RefreshToken == "" && c.AccessToken == "") {
+		fmt.Fprintln(stdout, "API keys stay valid on the server; delete the key in the dashboard to revoke it.")
+		return
+	}
+
+	hc, err := newHTTPClient()
+	if err != nil {
+		fmt.Fprintf(stdout, "Warning: could not revoke session on the server: %v\n", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	token, hint := c.RefreshToken, "refresh_token"
+	if token == "" {
+		token, hint = c.AccessToken, "access_token"
+	}
+	if err := revokeOAuthToken(ctx, hc, token, hint); err != nil {
+		fmt.Fprintf(stdout, "Warning: could not revoke session on the server: %v\n", err)
+		return
+	}
+	fmt.Fprintln(stdout, "Revoked session on the server.")
+}
+
+// keyTypeFromPrefix infers test/live mode from the API key prefix.
+func keyTypeFromPrefix(key string) string {
+	switch {
+	case strings.Contains(key, "_live_"):
+		return "live"
+	case strings.Contains(key, "_test_"):
+		return "test"
+	}
+	return "unknown"
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// deviceLogin runs the OAuth device authorization flow end to end.
+func deviceLogin(ctx context.Context, launchBrowser bool) (*oauthToken, error) {
+	hc, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := requestDeviceCode(ctx, hc)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyURL := auth.VerificationURI
+	if auth.VerificationURIComplete != "" {
+		verifyURL = auth.VerificationURIComplete
+	}
+
+	fmt.Fprintln(progress(), "🔐 To log in, open this URL in your browser:")
+	fmt.Fprintf(progress(), "   %s\n\n", verifyURL)
+	fmt.Fprintf(progress(), "   and confirm the code: %s\n\n", auth.UserCode)
+	if launchBrowser {
+		if err := openBrowser(verifyURL); err == nil {
+			fmt.Fprintln(progress(), "Opened your browser. Waiting for approval... (Ctrl+C to cancel)")
+		} else {
+			fmt.Fprintln(progress(), "Waiting for approval... (Ctrl+C to cancel)")
+		}
+	} else {
+		fmt.Fprintln(progress(), "Waiting for approval... (Ctrl+C to cancel)")
+	}
+
+	return pollDeviceToken(ctx, hc, auth)
+}
+
 func init() {
 	rootCmd.AddCommand(authCmd)
 	authCmd.AddCommand(loginCmd)
+	authCmd.AddCommand(whoamiCmd)
+	authCmd.AddCommand(statusCmd)
+	authCmd.AddCommand(logoutCmd)
+
+	loginCmd.Flags().Bool("no-browser", false, "Print the login URL without opening a browser")
 }
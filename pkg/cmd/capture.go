@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Every delivery 'sapliy listen' receives is saved as one JSON file in
+// ~/.sapliy/captures, so it can be browsed and replayed later. Capture IDs
+// start with the receive time in hex, so they sort by age.
+
+// maxCaptures is how many captures are kept; older ones are pruned.
+const maxCaptures = 1000
+
+// Signature verdicts recorded with a capture.
+const (
+	verdictValid      = "valid"
+	verdictLegacy     = "legacy"
+	verdictInvalid    = "invalid"
+	verdictUnverified = "unverified"
+	verdictUnsigned   = "unsigned" // no signature, and verification disabled
+	verdictSigned     = "signed"   // signed by the CLI itself, in --stream mode
+)
+
+// capture is a delivery received by 'sapliy listen'. Body holds the exact
+// bytes received.
+type capture struct {
+	ID             string           `json:"id"`
+	ReceivedAt     time.Time        `json:"received_at"`
+	Source         string           `json:"source"`
+	Method         string           `json:"method"`
+	Path           string           `json:"path"`
+	Headers        http.Header      `json:"headers"`
+	Body           []byte           `json:"body"`
+	EventID        string           `json:"event_id,omitempty"`
+	EventType      string           `json:"event_type,omitempty"`
+	Signature      string           `json:"signature"`
+	SignatureError string           `json:"signature_error,omitempty"`
+	Forward        *captureForward  `json:"forward,omitempty"`
+	Response       *captureResponse `json:"response,omitempty"`
+}
+
+// captureForward is the local application's response to a captured
+// delivery.
+type captureForward struct {
+	URL          string `json:"url"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	LatencyMS    int64  `json:"latency_ms"`
+	Error        string `json:"error,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// captureResponse is the response the listener sent for a captured
+// delivery.
+type captureResponse struct {
+	StatusCode int    `json:"status_code"`
+	Source     string `json:"source"`
+	Rule       string `json:"rule,omitempty"`
+	Note       string `json:"note,omitempty"`
+	DelayMS    int64  `json:"delay_ms,omitempty"`
+	Body       string `json:"body,omitempty"`
+}
+
+// newCapture records a delivery as received.
+func newCapture(source, method, path string, header http.Header, body []byte) *capture {
+	now := time.Now()
+	h := readWebhookHeaders(header)
+	return &capture{
+		ID:         newCaptureID(now),
+		ReceivedAt: now.UTC(),
+		Source:     source,
+		Method:     method,
+		Path:       path,
+		Headers:    header.Clone(),
+		Body:       body,
+		EventID:    h.EventID,
+		EventType:  h.EventType,
+	}
+}
+
+func newCaptureID(at time.Time) string {
+	var b [3]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("cap_%011x%s", at.UnixMilli(), hex.EncodeToString(b[:]))
+}
+
+// setForward records the outcome of forwarding the delivery.
+func (c *capture) setForward(target string, res *forwardResult, err error) {
+	c.Forward = &captureForward{URL: target}
+	if err != nil {
+		c.Forward.Error = err.Error()
+		return
+	}
+	c.Forward.StatusCode = res.StatusCode
+	c.Forward.LatencyMS = res.Latency.Milliseconds()
+	c.Forward.ResponseBody = truncate(string(res.Body), 4000)
+}
+
+// setResponse records the response sent for the delivery.
+func (c *capture) setResponse(r *listenReply) {
+	c.Response = &captureResponse{
+		StatusCode: r.Status,
+		Source:     r.Source,
+		Rule:       r.Rule,
+		Note:       r.Note,
+		DelayMS:    r.Delay.Milliseconds(),
+		Body:       truncate(string(r.Body), 4000),
+	}
+}
+
+// describe explains the response for display, e.g. `503 Service
+// Unavailable after 2s (rule "flaky", attempt 1 of 3)`.
+func (r *captureResponse) describe() string {
+	s := fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	if r.DelayMS > 0 {
+		s += " after " + (time.Duration(r.DelayMS) * time.Millisecond).String()
+	}
+	switch r.Source {
+	case replyRule:
+		s += fmt.Sprintf(" (rule %q, %s)", r.Rule, r.Note)
+	case replyApplication:
+		s += " (from the application)"
+	}
+	return s
+}
+
+// forwardOutcome describes the forward result for display.
+func (c *capture) forwardOutcome() string {
+	switch {
+	case c.Forward == nil:
+		return "—"
+	case c.Forward.Error != "":
+		return "error"
+	default:
+		return fmt.Sprintf("%d", c.Forward.StatusCode)
+	}
+}
+
+// responseOutcome describes the response sent for display.
+func (c *capture) responseOutcome() string {
+	if c.Response == nil {
+		return "—"
+	}
+	if c.Response.Source == replyRule {
+		return fmt.Sprintf("%d (rule)", c.Response.StatusCode)
+	}
+	return fmt.Sprintf("%d", c.Response.StatusCode)
+}
+
+func capturesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".sapliy", "captures"), nil
+}
+
+// saveCapture writes c to the store and prunes the oldest captures beyond
+// maxCaptures.
+func saveCapture(c *capture) error {
+	dir, err := capturesDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, c.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	names, err := captureFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > maxCaptures {
+		os.Remove(filepath.Join(dir, names[0]))
+		names = names[1:]
+	}
+	return nil
+}
+
+// captureFiles lists the capture files in dir, oldest first.
+func captureFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name := e.Name(); strings.HasPrefix(name, "cap_") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// loadCaptures returns all captures, newest first. Unreadable captures,
+// e.g. left truncated by a crash, are skipped with a warning.
+func loadCaptures() ([]capture, error) {
+	dir, err := capturesDir()
+	if err != nil {
+		return nil, err
+	}
+	names, err := captureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	captures := make([]capture, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		c, err := readCapture(filepath.Join(dir, names[i]))
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: skipping capture: %v\n", err)
+			continue
+		}
+		captures = append(captures, *c)
+	}
+	return captures, nil
+}
+
+// findCapture loads a capture by ID or unique ID prefix.
+func findCapture(id string) (*capture, error) {
+	dir, err := capturesDir()
+	if err != nil {
+		return nil, err
+	}
+	names, err := captureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, id) {
+			matches = append(matches, name)
+		}
+	}
+	switch {
+	case len(matches) == 0 || id == "":
+		e := newCLIError(kindNotFound, "capture %q not found", id)
+		e.Hint = "list captures with 'sapliy listen history'"
+		return nil, e
+	case len(matches) > 1 && !slices.Contains(matches, id+".json"):
+		return nil, validationError("capture ID %q is ambiguous (%d captures match)", id, len(matches))
+	case len(matches) > 1:
+		matches = []string{id + ".json"}
+	}
+	return readCapture(filepath.Join(dir, matches[0]))
+}
+
+func readCapture(path string) (*capture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c capture
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &c, nil
+}
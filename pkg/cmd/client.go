@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	fintech "github.com/sapliy/fintech-sdk-go"
+	"github.com/spf13/viper"
+)
+
+// Every command talks to the API through the clients built here, so base URL,
+// timeout, retries, TLS trust, proxy and User-Agent are applied the same way
+// everywhere: SDK calls, raw API requests, OAuth calls and the event stream.
+
+const defaultRequestTimeout = 30 * time.Second
+
+var (
+	transportOnce sync.Once
+	transport     http.RoundTripper
+	transportErr  error
+)
+
+// newAPIClient returns an SDK client for the configured API, authenticated
+// with session tokens derived from apiKey.
+func newAPIClient(apiKey string) (*fintech.Client, error) {
+	hc, err := sessionHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return fintech.NewClient(apiKey, fintech.WithBaseURL(apiBaseURL()), fintech.WithHTTPClient(hc)), nil
+}
+
+// newHTTPClient returns an unauthenticated client, used for OAuth endpoints.
+func newHTTPClient() (*http.Client, error) {
+	rt, err := httpTransport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// sessionHTTPClient returns an HTTP client that authenticates with
+// short-lived session tokens.
+func sessionHTTPClient() (*http.Client, error) {
+	rt, err := httpTransport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &sessionTransport{base: rt}}, nil
+}
+
+// newWebSocketDialer returns a dialer for the event stream with the same TLS
+// and proxy settings as the HTTP clients.
+func newWebSocketDialer() (*websocket.Dialer, error) {
+	tlsConfig, err := tlsClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	return &websocket.Dialer{
+		Proxy:            proxy,
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: requestTimeout(),
+	}, nil
+}
+
+// userAgent identifies the CLI and its version to the server.
+func userAgent() string {
+	return fmt.Sprintf("sapliy-cli/%s (%s; %s)", rootCmd.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// requestTimeout returns the per-attempt timeout from --timeout or config.
+// Zero disables the timeout.
+func requestTimeout() time.Duration {
+	raw := viper.GetString("timeout")
+	if raw == "" {
+		return defaultRequestTimeout
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: invalid timeout %q, using %s\n", raw, defaultRequestTimeout)
+		return defaultRequestTimeout
+	}
+	return d
+}
+
+// httpTransport builds the shared transport once per invocation.
+func httpTransport() (http.RoundTripper, error) {
+	transportOnce.Do(func() {
+		tlsConfig, err := tlsClientConfig()
+		if err != nil {
+			transportErr = err
+			return
+		}
+		proxy, err := proxyFunc()
+		if err != nil {
+			transportErr = err
+			return
+		}
+
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		t.Proxy = proxy
+		transport = &userAgentTransport{
+			base: &retryTransport{base: t, timeout: requestTimeout()},
+		}
+	})
+	return transport, transportErr
+}
+
+// webhookHTTPClient returns a client for delivering webhooks to receivers.
+// It uses the same TLS and proxy settings as API calls, but no session
+// auth, retries or redirects, so the receiver's first response is reported
+// as is.
+func webhookHTTPClient(timeout time.Duration) (*http.Client, error) {
+	client, err := relayHTTPClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = &userAgentTransport{base: client.Transport}
+	return client, nil
+}
+
+// relayHTTPClient is webhookHTTPClient for passing on deliveries received
+// from someone else: it leaves their headers, User-Agent included, as they
+// are.
+func relayHTTPClient(timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := tlsClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	t.Proxy = proxy
+	return &http.Client{
+		Transport: t,
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// keepUserAgent stops net/http from adding its default User-Agent to a
+// relayed request whose original had none.
+func keepUserAgent(h http.Header) {
+	if _, ok := h["User-Agent"]; !ok {
+		h["User-Agent"] = []string{""}
+	}
+}
+
+// tlsClientConfig trusts the system roots plus ca_bundle, and skips
+// verification entirely when insecure_skip_verify is set.
+func tlsClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if bundle := viper.GetString("ca_bundle"); bundle != "" {
+		pem, err := os.ReadFile(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", bundle)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if viper.GetBool("insecure_skip_verify") {
+		fmt.Fprintln(stderr, "Warning: TLS certificate verification is disabled")
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg, nil
+}
+
+// proxyFunc uses the proxy setting when present and the standard
+// HTTPS_PROXY/HTTP_PROXY/NO_PROXY variables otherwise.
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	raw := viper.GetString("proxy")
+	if raw == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", raw)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// userAgentTransport stamps every request with the CLI's User-Agent.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set("User-Agent", userAgent())
+	return t.base.RoundTrip(r)
+}
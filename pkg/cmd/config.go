@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configSetting is a key with its effective value and where it comes from.
+type configSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and change CLI settings",
+	Long: `Read and change settings in the config file without editing it by hand.
+
+Only known keys are accepted and values are checked against their type, so a
+typo is reported instead of being silently ignored. Run 'sapliy config list'
+to see every key with its current value.
+
+Profile settings (api_url, org_id, current_zone, ...) are written to the
+active profile when one exists, otherwise to the top level of the file.
+Use --global to always write to the top level.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+
+		setting, err := effectiveSetting(k)
+		if err != nil {
+			return err
+		}
+		if setting.Value == "" && !structuredOutput() {
+			return notFoundError("%s is not set", k.Name)
+		}
+		return printObject(setting, func() {
+			fmt.Fprintln(stdout, setting.Value)
+		})
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [key] [value]",
+	Short: "Change a setting in the config file",
+	Example: `  sapliy config set api_url https://api.sapliy.io
+  sapliy config set current_zone zone_123
+  sapliy config set verbose true --global`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+		if k.Scope == scopeRuntime {
+			return validationError("%s cannot be saved; use --%s or %s", k.Name, k.Flag, k.Env)
+		}
+
+		value, err := k.parseConfigValue(args[1])
+		if err != nil {
+			return validationError("%w", err)
+		}
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+
+		global, _ := cmd.Flags().GetBool("global")
+		profile := configTargetProfile(doc, k, global)
+
+		if k.Name == "api_key" {
+			// API keys go to the encrypted store, never into the config file.
+			if profile == "" {
+				err = storeGlobalCredential(doc, &credential{APIKey: args[1]})
+			} else {
+				err = storeProfileCredential(doc, profile, &credential{APIKey: args[1]})
+			}
+			if err != nil {
+				return fmt.Errorf("save credentials: %w", err)
+			}
+		} else {
+			doc.set(configDocKey(profile, k.Name), value)
+		}
+
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+
+		display := args[1]
+		if k.Secret {
+			display = maskSecret(display)
+		}
+		fmt.Fprintf(stdout, "Set %s = %s (%s)\n", k.Name, display, configTargetLabel(profile))
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Remove a setting from the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, ok := lookupConfigKey(args[0])
+		if !ok {
+			return unknownConfigKeyError(args[0])
+		}
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+
+		global, _ := cmd.Flags().GetBool("global")
+		profile := configTargetProfile(doc, k, global)
+		removed := doc.unset(configDocKey(profile, k.Name))
+		if k.Name == "api_key" {
+			stored, err := removeStoredCredential(doc, profile)
+			if err != nil {
+				return fmt.Errorf("remove credentials: %w", err)
+			}
+			removed = removed || stored
+		}
+		if !removed {
+			fmt.Fprintf(stdout, "%s is not set (%s)\n", k.Name, configTargetLabel(profile))
+			return nil
+		}
+
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+		fmt.Fprintf(stdout, "Unset %s (%s)\n", k.Name, configTargetLabel(profile))
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all known settings and their values",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showOrigin, _ := cmd.Flags().GetBool("show-origin")
+
+		settings := make([]configSetting, len(configSchema))
+		for i := range configSchema {
+			k := &configSchema[i]
+			setting, err := effectiveSetting(k)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %s: %v\n", k.Name, err)
+			}
+			settings[i] = setting
+		}
+
+		columns := []column[configSetting]{
+			{"KEY", func(s configSetting) string { return s.Key }},
+			{"VALUE", func(s configSetting) string { return s.Value }},
+		}
+		if showOrigin {
+			columns = append(columns, column[configSetting]{"ORIGIN", func(s configSetting) string { return s.Origin }})
+		}
+		err := printList(settings, columns, func() {
+			if showOrigin {
+				fmt.Fprintf(stdout, "%-16s %-40s %s\n", "KEY", "VALUE", "ORIGIN")
+			} else {
+				fmt.Fprintf(stdout, "%-16s %s\n", "KEY", "VALUE")
+			}
+			for _, s := range settings {
+				if showOrigin {
+					fmt.Fprintf(stdout, "%-16s %-40s %s\n", s.Key, valueOrDash(s.Value), s.Origin)
+				} else {
+					fmt.Fprintf(stdout, "%-16s %s\n", s.Key, valueOrDash(s.Value))
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+			return nil
+		}
+		if errs := validateConfigDoc(doc); len(errs) > 0 {
+			fmt.Fprintf(stderr, "Problems in %s:\n", path)
+			for _, err := range errs {
+				fmt.Fprintf(stderr, "  - %v\n", err)
+			}
+		}
+		return nil
+	},
+}
+
+var configDescribeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Describe every known setting",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printList(configSchema, []column[configKey]{
+			{"NAME", func(k configKey) string { return k.Name }},
+			{"TYPE", func(k configKey) string { return k.Type }},
+			{"SCOPE", func(k configKey) string { return k.Scope }},
+			{"DEFAULT", func(k configKey) string {
+				if k.Default == nil {
+					return ""
+				}
+				return fmt.Sprint(k.Default)
+			}},
+			{"ENV", func(k configKey) string { return k.Env }},
+			{"FLAG", func(k configKey) string { return k.Flag }},
+			{"DESCRIPTION", func(k configKey) string { return k.Description }},
+		}, func() {
+			for _, k := range configSchema {
+				fmt.Fprintf(stdout, "%s (%s, %s)\n", k.Name, k.Type, k.Scope)
+				fmt.Fprintf(stdout, "  %s\n", k.Description)
+				if k.Default != nil {
+					fmt.Fprintf(stdout, "  default: %v\n", k.Default)
+				}
+				if k.Env != "" {
+					fmt.Fprintf(stdout, "  env:     %s\n", k.Env)
+				}
+				if k.Flag != "" {
+					fmt.Fprintf(stdout, "  flag:    --%s\n", k.Flag)
+				}
+				if len(k.Allowed) > 0 {
+					fmt.Fprintf(stdout, "  values:  %s\n", strings.Join(k.Allowed, ", "))
+				}
+				fmt.Fprintln(stdout)
+			}
+		})
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $EDITOR and validate it on save",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configFilePath()
+		if err != nil {
+			return err
+		}
+
+		original, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read config: %w", err)
+		}
+
+		// Edit a copy so a half-finished or invalid file never becomes live.
+		tmp, err := os.CreateTemp("", "sapliy-config-*"+filepath.Ext(path))
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(original)
+		tmp.Close()
+		if err != nil {
+			return err
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			if err := runEditor(tmp.Name()); err != nil {
+				return fmt.Errorf("run editor: %w", err)
+			}
+
+			edited, err := os.ReadFile(tmp.Name())
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(edited, original) {
+				fmt.Fprintln(stdout, "No changes.")
+				return nil
+			}
+
+			errs := validateConfigBytes(path, edited)
+			if len(errs) == 0 {
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					return fmt.Errorf("save config: %w", err)
+				}
+				if err := saveEditedConfig(path, edited); err != nil {
+					return fmt.Errorf("save config: %w", err)
+				}
+				fmt.Fprintf(stdout, "Saved %s\n", path)
+				return nil
+			}
+
+			fmt.Fprintln(stdout, "The config has problems:")
+			for _, err := range errs {
+				fmt.Fprintf(stdout, "  - %v\n", err)
+			}
+			fmt.Fprint(stdout, "Edit again? [Y/n] ")
+			answer, err := reader.ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); (err != nil && a == "") || a == "n" || a == "no" {
+				fmt.Fprintln(stdout)
+				return validationError("config has problems; discarded changes")
+			}
+		}
+	},
+}
+
+// requireSetting returns the effective value of key, or a validation error
+// explaining how to set it.
+func requireSetting(key string) (string, error) {
+	if v := viper.GetString(key); v != "" {
+		return v, nil
+	}
+	e := newCLIError(kindValidation, "%s is not set", key)
+	e.Hint = fmt.Sprintf("run 'sapliy config set %s <value>'", key)
+	if k, ok := lookupConfigKey(key); ok && k.Env != "" {
+		e.Hint += " or set " + k.Env
+	}
+	return "", e
+}
+
+// effectiveSetting returns the effective value of k, masked if it is a
+// secret, and where it comes from. api_key is resolved the way commands
+// resolve it, so a key in the credential store is reported too.
+func effectiveSetting(k *configKey) (configSetting, error) {
+	if k.Name == "api_key" {
+		key, source, err := resolveCredential()
+		if err != nil || key == "" {
+			return configSetting{Key: k.Name, Origin: "unset"}, err
+		}
+		return configSetting{Key: k.Name, Value: maskSecret(key), Origin: source}, nil
+	}
+	return configSetting{Key: k.Name, Value: configValueString(k), Origin: settingOrigin(k.Name)}, nil
+}
+
+// configValueString formats the effective value of k for display, masking
+// secrets.
+func configValueString(k *configKey) string {
+	if !viper.IsSet(k.Name) {
+		return ""
+	}
+	value := viper.GetString(k.Name)
+	if k.Secret && value != "" {
+		return maskSecret(value)
+	}
+	return value
+}
+
+// configTargetProfile decides where set and unset write k: the active profile
+// for profile settings when that profile exists, otherwise the top level.
+func configTargetProfile(doc configDoc, k *configKey, global bool) string {
+	if global || k.Scope != scopeProfile {
+		return ""
+	}
+	name := activeProfileName()
+	if _, exists := doc.get("profiles." + name); exists {
+		return name
+	}
+	return ""
+}
+
+func configDocKey(profile, key string) string {
+	if profile == "" {
+		return key
+	}
+	return fmt.Sprintf("profiles.%s.%s", profile, key)
+}
+
+func configTargetLabel(profile string) string {
+	if profile == "" {
+		return "top level"
+	}
+	return "profile " + profile
+}
+
+// storeGlobalCredential saves c in the credential store for the top-level
+// settings used when no profile is configured.
+func storeGlobalCredential(doc configDoc, c *credential) error {
+	store, err := openCredentialStore()
+	if err != nil {
+		return err
+	}
+
+	store.put(globalCredentialID, c)
+	if err := store.save(); err != nil {
+		return err
+	}
+
+	doc.set("credential", globalCredentialID)
+	doc.unset("api_key")
+	return nil
+}
+
+// removeStoredCredential deletes the credential store entry the top level
+// or a profile refers to, and the reference itself. It reports whether
+// there was one.
+func removeStoredCredential(doc configDoc, profile string) (bool, error) {
+	key := configDocKey(profile, "credential")
+	raw, ok := doc.get(key)
+	if !ok {
+		return false, nil
+	}
+	store, err := openCredentialStore()
+	if err != nil {
+		return false, err
+	}
+	if store.remove(fmt.Sprint(raw)) {
+		if err := store.save(); err != nil {
+			return false, err
+		}
+	}
+	doc.unset(key)
+	return true, nil
+}
+
+// saveEditedConfig writes a validated config file. A plaintext api_key
+// typed into it is moved into the credential store instead of being saved.
+func saveEditedConfig(path string, data []byte) error {
+	doc, err := parseConfigDoc(path, data)
+	if err != nil {
+		return err
+	}
+	migrated, err := migratePlaintextKeys(doc)
+	if err != nil {
+		return fmt.Errorf("move API keys to the credential store: %w", err)
+	}
+	if len(migrated) == 0 {
+		return os.WriteFile(path, data, 0o600)
+	}
+	for _, source := range migrated {
+		fmt.Fprintf(stdout, "Moved plaintext API key from %s into the credential store\n", source)
+	}
+	return doc.save(path)
+}
+
+// validateConfigBytes parses and validates edited config file contents.
+func validateConfigBytes(path string, data []byte) []error {
+	doc, err := parseConfigDoc(path, data)
+	if err != nil {
+		return []error{err}
+	}
+	return validateConfigDoc(doc)
+}
+
+// runEditor opens path in $VISUAL or $EDITOR and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// Editors are often configured with arguments, e.g. "code --wait".
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], path)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configDescribeCmd)
+	configCmd.AddCommand(configEditCmd)
+
+	configSetCmd.Flags().Bool("global", false, "write to the top level instead of the active profile")
+	configUnsetCmd.Flags().Bool("global", false, "remove from the top level instead of the active profile")
+	configListCmd.Flags().Bool("show-origin", false, "show where each value comes from")
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// configDoc is the on-disk representation of the config file. Writes go
+// through it rather than viper.WriteConfig so that environment variables,
+// flags and defaults never leak into the file.
+type configDoc map[string]interface{}
+
+// configFilePath returns the config file the CLI reads from and writes to.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".sapliy.yaml"), nil
+}
+
+// loadConfigDoc reads the config file. A missing file yields an empty document.
+func loadConfigDoc() (configDoc, string, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return configDoc{}, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc, err := parseConfigDoc(path, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return doc, path, nil
+}
+
+// parseConfigDoc decodes config file contents, choosing the format from path.
+func parseConfigDoc(path string, data []byte) (configDoc, error) {
+	doc := configDoc{}
+	var err error
+	if isJSONConfig(path) {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if doc == nil {
+		doc = configDoc{}
+	}
+	return doc, nil
+}
+
+// save writes the document back to path, creating it if needed.
+func (d configDoc) save(path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isJSONConfig(path) {
+		data, err = json.MarshalIndent(d, "", "  ")
+	} else {
+		data, err = yaml.Marshal(map[string]interface{}(d))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// get looks up a dotted key such as "profiles.staging.api_url".
+func (d configDoc) get(key string) (interface{}, bool) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	var cur interface{} = map[string]interface{}(d)
+	for _, part := range parts {
+		m, ok := asStringMap(cur)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// set assigns a dotted key, creating intermediate maps as needed.
+func (d configDoc) set(key string, value interface{}) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := map[string]interface{}(d)
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asStringMap(m[part])
+		if !ok {
+			next = map[string]interface{}{}
+		}
+		m[part] = next
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// unset removes a dotted key and reports whether it was present.
+func (d configDoc) unset(key string) bool {
+	parts := strings.Split(strings.ToLower(key), ".")
+	m := map[string]interface{}(d)
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asStringMap(m[part])
+		if !ok {
+			return false
+		}
+		m[part] = next
+		m = next
+	}
+	last := parts[len(parts)-1]
+	if _, ok := m[last]; !ok {
+		return false
+	}
+	delete(m, last)
+	return true
+}
+
+// settingOrigin reports where the effective value of key comes from: a flag,
+// an environment variable, the active profile, the config file, or nowhere.
+func settingOrigin(key string) string {
+	k, known := lookupConfigKey(key)
+	if known && k.Flag != "" {
+		if f := rootCmd.PersistentFlags().Lookup(k.Flag); f != nil && f.Changed {
+			return "flag --" + k.Flag
+		}
+	}
+	if known && k.Env != "" {
+		if _, set := os.LookupEnv(k.Env); set {
+			return "env " + k.Env
+		}
+	}
+	profile := activeProfileName()
+	if viper.IsSet(fmt.Sprintf("profiles.%s.%s", profile, key)) {
+		return "profile " + profile
+	}
+	if viper.InConfig(key) {
+		return "config file"
+	}
+	if known && k.Default != nil {
+		return "default"
+	}
+	return "unset"
+}
+
+// asStringMap normalizes the map types produced by the YAML and JSON decoders.
+func asStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case configDoc:
+		return m, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			out[fmt.Sprint(k)] = val
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func isJSONConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
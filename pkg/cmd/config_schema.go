@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config key types understood by the schema.
+const (
+	typeString   = "string"
+	typeBool     = "bool"
+	typeInt      = "int"
+	typeDuration = "duration"
+	typeURL      = "url"
+)
+
+// Where a config key may be stored.
+const (
+	// scopeProfile keys live in the active profile (profiles.<name>.<key>),
+	// falling back to the top level of the config file.
+	scopeProfile = "profile"
+	// scopeGlobal keys live at the top level of the config file.
+	scopeGlobal = "global"
+	// scopeRuntime keys come only from flags and environment variables.
+	scopeRuntime = "runtime"
+)
+
+// configKey declares a setting the CLI understands.
+type configKey struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Default     interface{}        `json:"default,omitempty"`
+	Env         string             `json:"env,omitempty"`
+	Flag        string             `json:"flag,omitempty"`
+	Scope       string             `json:"scope"`
+	Secret      bool               `json:"secret,omitempty"`
+	Allowed     []string           `json:"allowed,omitempty"`
+	Check       func(string) error `json:"-"`
+	Description string             `json:"description"`
+}
+
+// configSchema lists every known setting. Anything else in the config file
+// is reported as unknown by 'sapliy config list' and rejected by 'config edit'.
+var configSchema = []configKey{
+	{Name: "api_url", Type: typeURL, Default: defaultAPIURL, Env: "SAPLIY_API_URL", Scope: scopeProfile,
+		Description: "Base URL of the Sapliy API"},
+	{Name: "auth_url", Type: typeURL, Env: "SAPLIY_AUTH_URL", Scope: scopeProfile,
+		Description: "Base URL of the OAuth endpoints (default: api_url)"},
+	{Name: "org_id", Type: typeString, Env: "SAPLIY_ORG_ID", Scope: scopeProfile,
+		Description: "Organization ID"},
+	{Name: "current_zone", Type: typeString, Env: "SAPLIY_ZONE", Scope: scopeProfile,
+		Description: "Zone used when --zone is not given"},
+	{Name: "credential", Type: typeString, Scope: scopeProfile,
+		Description: "ID of the entry in the encrypted credential store"},
+	{Name: "api_key", Type: typeString, Env: "SAPLIY_API_KEY", Flag: "api-key", Scope: scopeProfile, Secret: true,
+		Description: "API key (stored in the credential store, not the config file)"},
+	{Name: "webhook_secret", Type: typeString, Env: "SAPLIY_WEBHOOK_SECRET", Scope: scopeProfile, Secret: true,
+		Description: "Secret used to sign and verify webhooks; comma-separate several while rotating"},
+	{Name: "webhook_tolerance", Type: typeDuration, Default: "5m", Env: "SAPLIY_WEBHOOK_TOLERANCE", Scope: scopeProfile,
+		Description: "How far a webhook timestamp may be from the local clock in 'sapliy listen'"},
+	{Name: "webhook_legacy_signatures", Type: typeBool, Default: false, Scope: scopeProfile,
+		Description: "Accept legacy body-only webhook signatures in 'sapliy listen'"},
+	{Name: "timeout", Type: typeDuration, Default: "30s", Env: "SAPLIY_TIMEOUT", Flag: "timeout", Scope: scopeGlobal,
+		Description: "Timeout for each API request attempt (0 disables it)"},
+	{Name: "max_retries", Type: typeInt, Default: defaultMaxRetries, Env: "SAPLIY_MAX_RETRIES", Scope: scopeGlobal,
+		Description: "Retries for rate-limited, failed (5xx) or interrupted requests"},
+	{Name: "ca_bundle", Type: typeString, Env: "SAPLIY_CA_BUNDLE", Scope: scopeProfile,
+		Description: "PEM file with extra CA certificates to trust, e.g. for a local TLS stack"},
+	{Name: "insecure_skip_verify", Type: typeBool, Default: false, Flag: "insecure-skip-verify", Scope: scopeProfile,
+		Description: "Skip TLS certificate verification (local development only)"},
+	{Name: "proxy", Type: typeString, Env: "SAPLIY_PROXY", Scope: scopeProfile,
+		Description: "HTTP(S) proxy URL (default: HTTPS_PROXY/HTTP_PROXY)"},
+	{Name: "oauth_client_id", Type: typeString, Default: defaultOAuthClientID, Scope: scopeGlobal,
+		Description: "OAuth client ID used by 'sapliy auth login'"},
+	{Name: "active_profile", Type: typeString, Scope: scopeGlobal,
+		Description: "Profile used when --profile and SAPLIY_PROFILE are not set"},
+	{Name: "output", Type: typeString, Default: outputTable, Env: "SAPLIY_OUTPUT", Flag: "output", Scope: scopeGlobal,
+		Check: checkOutputFormat, Description: "Output format: table, json, yaml, csv, go-template=... or go-template-file=..."},
+	{Name: "verbose", Type: typeBool, Default: false, Flag: "verbose", Scope: scopeGlobal,
+		Description: "Enable verbose output"},
+	{Name: "profile", Type: typeString, Env: "SAPLIY_PROFILE", Flag: "profile", Scope: scopeRuntime,
+		Description: "Profile to use for a single invocation"},
+}
+
+// profileKeys are the settings each profile carries. When a profile is
+// active its values take precedence over the top-level config keys, but
+// environment variables still override both. api_key is only read for
+// configs that predate the credential store.
+var profileKeys = configKeysInScope(scopeProfile)
+
+func configKeysInScope(scope string) []string {
+	var names []string
+	for _, k := range configSchema {
+		if k.Scope == scope {
+			names = append(names, k.Name)
+		}
+	}
+	return names
+}
+
+// lookupConfigKey finds a key in the schema.
+func lookupConfigKey(name string) (*configKey, bool) {
+	name = strings.ToLower(name)
+	for i := range configSchema {
+		if configSchema[i].Name == name {
+			return &configSchema[i], true
+		}
+	}
+	return nil, false
+}
+
+// unknownConfigKeyError explains a key that is not in the schema, suggesting
+// the closest known key.
+func unknownConfigKeyError(name string) error {
+	best, bestDist := "", 4
+	for _, k := range configSchema {
+		if d := editDistance(name, k.Name); d < bestDist {
+			best, bestDist = k.Name, d
+		}
+	}
+	if best != "" {
+		return validationError("unknown config key %q (did you mean %q?)", name, best)
+	}
+	return validationError("unknown config key %q; run 'sapliy config list' to see known keys", name)
+}
+
+// bindConfigSchema registers defaults and environment variables with viper.
+func bindConfigSchema() {
+	for _, k := range configSchema {
+		if k.Env != "" {
+			viper.BindEnv(k.Name, k.Env)
+		}
+		if k.Default != nil {
+			viper.SetDefault(k.Name, k.Default)
+		}
+	}
+}
+
+// parseConfigValue converts a command-line string into the key's type.
+func (k *configKey) parseConfigValue(raw string) (interface{}, error) {
+	var (
+		value interface{}
+		err   error
+	)
+	switch k.Type {
+	case typeBool:
+		value, err = strconv.ParseBool(raw)
+	case typeInt:
+		value, err = strconv.Atoi(raw)
+	default:
+		value = raw
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a %s: %q", k.Name, k.Type, raw)
+	}
+	return value, k.validate(value)
+}
+
+// validate checks a decoded value against the key's type and allowed values.
+func (k *configKey) validate(value interface{}) error {
+	switch k.Type {
+	case typeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", k.Name)
+		}
+	case typeInt:
+		switch v := value.(type) {
+		case int:
+		case float64:
+			if v != float64(int(v)) {
+				return fmt.Errorf("%s must be a whole number", k.Name)
+			}
+		default:
+			return fmt.Errorf("%s must be a whole number", k.Name)
+		}
+	case typeDuration:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a duration such as 30s or 2m", k.Name)
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("%s must be a duration such as 30s or 2m: %q", k.Name, s)
+		}
+	case typeURL:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a URL", k.Name)
+		}
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL: %q", k.Name, s)
+		}
+	case typeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string", k.Name)
+		}
+	}
+
+	if k.Check != nil {
+		if err := k.Check(fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("%s: %w", k.Name, err)
+		}
+	}
+
+	if len(k.Allowed) > 0 {
+		s := fmt.Sprint(value)
+		for _, a := range k.Allowed {
+			if s == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s: %q", k.Name, strings.Join(k.Allowed, ", "), s)
+	}
+	return nil
+}
+
+// validateConfigDoc checks a whole config document against the schema and
+// returns every problem found.
+func validateConfigDoc(doc configDoc) []error {
+	var errs []error
+
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := doc[key]
+		if key == "profiles" {
+			errs = append(errs, validateProfiles(value)...)
+			continue
+		}
+
+		k, ok := lookupConfigKey(key)
+		if !ok {
+			errs = append(errs, unknownConfigKeyError(key))
+			continue
+		}
+		if k.Scope == scopeRuntime {
+			errs = append(errs, fmt.Errorf("%s cannot be set in the config file (use --%s or %s)", k.Name, k.Flag, k.Env))
+			continue
+		}
+		if err := k.validate(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func validateProfiles(value interface{}) []error {
+	profiles, ok := asStringMap(value)
+	if !ok {
+		return []error{fmt.Errorf("profiles must be a map of profile names to settings")}
+	}
+
+	var errs []error
+	for _, name := range sortedKeys(profiles) {
+		if !profileNameRe.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid profile name %q", name))
+		}
+		settings, ok := asStringMap(profiles[name])
+		if !ok {
+			if profiles[name] != nil {
+				errs = append(errs, fmt.Errorf("profile %q must be a map of settings", name))
+			}
+			continue
+		}
+		for _, key := range sortedKeys(settings) {
+			k, ok := lookupConfigKey(key)
+			if !ok {
+				errs = append(errs, fmt.Errorf("profile %q: %w", name, unknownConfigKeyError(key)))
+				continue
+			}
+			if k.Scope != scopeProfile {
+				errs = append(errs, fmt.Errorf("profile %q: %s cannot be set per profile", name, k.Name))
+				continue
+			}
+			if err := k.validate(settings[key]); err != nil {
+				errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			}
+		}
+	}
+	return errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -18,7 +18,7 @@ var connectCmd = &cobra.Command{
 	Short: "Connect to Sapliy Event Bus via WebSocket",
 	Long:  `Connects to the Sapliy backend event bus to stream events in real-time.`,
 	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		serverURL := "ws://localhost:8080/ws"
 		if len(args) > 0 {
 			serverURL = args[0]
@@ -29,23 +29,27 @@ var connectCmd = &cobra.Command{
 
 		u, err := url.Parse(serverURL)
 		if err != nil {
-			log.Fatal("Invalid URL:", err)
+			return validationError("invalid URL: %v", err)
 		}
 
-		fmt.Printf("🔌 Connecting to %s...\n", u.String())
+		fmt.Fprintf(stdout, "🔌 Connecting to %s...\n", u.String())
 
-		header := http.Header{}
+		header := http.Header{"User-Agent": {userAgent()}}
 		if apiKey != "" {
 			header.Set("Authorization", "Bearer "+apiKey)
 		}
 
-		c, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+		dialer, err := newWebSocketDialer()
 		if err != nil {
-			log.Fatal("Connection failed:", err)
+			return err
+		}
+		c, _, err := dialer.Dial(u.String(), header)
+		if err != nil {
+			return fmt.Errorf("connection failed: %w", err)
 		}
 		defer c.Close()
 
-		fmt.Println("✅ Connected! Listening for events...")
+		fmt.Fprintln(stdout, "✅ Connected! Listening for events...")
 
 		done := make(chan struct{})
 
@@ -58,13 +62,13 @@ var connectCmd = &cobra.Command{
 					log.Println("read-error:", err)
 					return
 				}
-				fmt.Printf("< %s\n", message)
+				fmt.Fprintf(stdout, "< %s\n", message)
 			}
 		}()
 
 		// Trigger logic
 		if trigger != "" {
-			fmt.Printf("> Triggering event: %s\n", trigger)
+			fmt.Fprintf(stdout, "> Triggering event: %s\n", trigger)
 			err := c.WriteMessage(websocket.TextMessage, []byte(trigger))
 			if err != nil {
 				log.Println("write-error:", err)
@@ -77,20 +81,20 @@ var connectCmd = &cobra.Command{
 		for {
 			select {
 			case <-done:
-				return
+				return nil
 			case <-interrupt:
-				fmt.Println("\nDisconnecting...")
+				fmt.Fprintln(stdout, "\nDisconnecting...")
 				// Cleanly close the connection by sending a close message
 				err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				if err != nil {
 					log.Println("write-close:", err)
-					return
+					return nil
 				}
 				select {
 				case <-done:
 				case <-time.After(time.Second):
 				}
-				return
+				return nil
 			}
 		}
 	},
@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// The credential store keeps secrets out of the config file. It is a JSON
+// envelope holding an AES-256-GCM ciphertext; the key is derived with
+// PBKDF2-SHA256 from a passphrase (SAPLIY_CREDENTIALS_PASSPHRASE, the
+// contents of SAPLIY_CREDENTIALS_KEY_FILE, or an interactive prompt).
+// Config files only reference entries by credential ID.
+
+const (
+	credentialStoreVersion = 1
+	credentialKDFIter      = 600000
+	credentialAAD          = "sapliy-credentials-v1"
+
+	// globalCredentialID holds a key migrated from the top-level api_key.
+	globalCredentialID = "global"
+	// profileCredentialPrefix starts the IDs of profile entries, so that no
+	// profile name can collide with globalCredentialID.
+	profileCredentialPrefix = "profile:"
+)
+
+// credential is a single secret entry in the store: either an API key or
+// the tokens obtained through 'sapliy auth login' in the browser. For an API
+// key, AccessToken and ExpiresAt only cache the session token it was
+// exchanged for.
+type credential struct {
+	APIKey       string    `json:"api_key,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitzero"`
+}
+
+type credentialEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type credentialStore struct {
+	path       string
+	key        []byte
+	salt       []byte
+	iterations int
+	creds      map[string]*credential
+}
+
+// unlockedStore caches the decrypted store so a command prompts at most once.
+var unlockedStore *credentialStore
+
+// credentialsPath returns the location of the encrypted credentials file.
+func credentialsPath() (string, error) {
+	if path := os.Getenv("SAPLIY_CREDENTIALS_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".sapliy", "credentials"), nil
+}
+
+// openCredentialStore decrypts the credential store, creating an empty one
+// (and choosing a passphrase) if none exists yet.
+func openCredentialStore() (*credentialStore, error) {
+	if unlockedStore != nil {
+		return unlockedStore, nil
+	}
+
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		passphrase, err := credentialPassphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		key, err := pbkdf2.Key(sha256.New, passphrase, salt, credentialKDFIter, 32)
+		if err != nil {
+			return nil, err
+		}
+		unlockedStore = &credentialStore{
+			path:       path,
+			key:        key,
+			salt:       salt,
+			iterations: credentialKDFIter,
+			creds:      map[string]*credential{},
+		}
+		return unlockedStore, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var env credentialEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if env.Version != credentialStoreVersion || env.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported credential store format in %s", path)
+	}
+
+	passphrase, err := credentialPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, env.Salt, env.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newCredentialCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(credentialAAD))
+	if err != nil {
+		return nil, errors.New("cannot decrypt credential store: wrong passphrase or key file")
+	}
+
+	creds := map[string]*credential{}
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("decode credential store: %w", err)
+	}
+
+	unlockedStore = &credentialStore{
+		path:       path,
+		key:        key,
+		salt:       env.Salt,
+		iterations: env.Iterations,
+		creds:      creds,
+	}
+	return unlockedStore, nil
+}
+
+func (s *credentialStore) get(id string) (*credential, bool) {
+	c, ok := s.creds[id]
+	return c, ok
+}
+
+func (s *credentialStore) put(id string, c *credential) {
+	s.creds[id] = c
+}
+
+func (s *credentialStore) remove(id string) bool {
+	if _, ok := s.creds[id]; !ok {
+		return false
+	}
+	delete(s.creds, id)
+	return true
+}
+
+// save re-encrypts the store with a fresh nonce and atomically replaces the file.
+func (s *credentialStore) save() error {
+	plaintext, err := json.Marshal(s.creds)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newCredentialCipher(s.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(credentialEnvelope{
+		Version:    credentialStoreVersion,
+		KDF:        "pbkdf2-sha256",
+		Iterations: s.iterations,
+		Salt:       s.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte(credentialAAD)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func newCredentialCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// credentialPassphrase obtains the secret the store key is derived from.
+// confirm asks twice when a new store is being created interactively.
+func credentialPassphrase(confirm bool) (string, error) {
+	if keyFile := os.Getenv("SAPLIY_CREDENTIALS_KEY_FILE"); keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("read credentials key file: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("credentials key file %s is empty", keyFile)
+		}
+		return key, nil
+	}
+	if passphrase := os.Getenv("SAPLIY_CREDENTIALS_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("credential store is locked: set SAPLIY_CREDENTIALS_PASSPHRASE or SAPLIY_CREDENTIALS_KEY_FILE")
+	}
+
+	prompt := "Credential store passphrase: "
+	if confirm {
+		prompt = "Choose a passphrase for the credential store: "
+	}
+	fmt.Fprint(stderr, prompt)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(first) == 0 {
+		return "", errors.New("passphrase must not be empty")
+	}
+
+	if confirm {
+		fmt.Fprint(stderr, "Confirm passphrase: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(first), nil
+}
+
+// resolveAPIKey returns the API key for this invocation. Browser logins
+// authenticate with their access token. An empty key means the user has not
+// logged in.
+func resolveAPIKey() (string, error) {
+	key, _, err := resolveCredential()
+	return key, err
+}
+
+// requireAPIKey is resolveAPIKey for commands that cannot run without a
+// credential.
+func requireAPIKey() (string, error) {
+	key, err := resolveAPIKey()
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", authError("not authenticated")
+	}
+	return key, nil
+}
+
+// resolveCredential returns the secret used to authenticate this invocation
+// and where it came from: --api-key first, then SAPLIY_API_KEY, then the
+// credential referenced by the active profile (or top-level config), then a
+// legacy plaintext api_key.
+func resolveCredential() (string, string, error) {
+	if key, _ := rootCmd.PersistentFlags().GetString("api-key"); key != "" {
+		registerSecret(key)
+		return key, "flag --api-key", nil
+	}
+	if key := os.Getenv("SAPLIY_API_KEY"); key != "" {
+		registerSecret(key)
+		return key, "env SAPLIY_API_KEY", nil
+	}
+
+	if id := storedCredentialID(); id != "" {
+		c, err := storedCredential(id)
+		if err != nil {
+			return "", "", err
+		}
+		source := fmt.Sprintf("credential store, id %q (%s)", id, settingOrigin("credential"))
+		if c.APIKey != "" {
+			return c.APIKey, source, nil
+		}
+		return c.AccessToken, source, nil
+	}
+
+	if key := viper.GetString("api_key"); key != "" {
+		return key, settingOrigin("api_key") + ", plaintext", nil
+	}
+	return "", "", nil
+}
+
+// storedCredentialID returns the credential store ID in effect, or "" when
+// --api-key or SAPLIY_API_KEY overrides it.
+func storedCredentialID() string {
+	if key, _ := rootCmd.PersistentFlags().GetString("api-key"); key != "" {
+		return ""
+	}
+	if os.Getenv("SAPLIY_API_KEY") != "" {
+		return ""
+	}
+	return viper.GetString("credential")
+}
+
+// storedCredential loads a credential by ID from the store.
+func storedCredential(id string) (*credential, error) {
+	store, err := openCredentialStore()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := store.get(id)
+	if !ok {
+		return nil, fmt.Errorf("credential %q not found in store; run 'sapliy auth login'", id)
+	}
+	registerSecret(c.APIKey)
+	registerSecret(c.AccessToken)
+	registerSecret(c.RefreshToken)
+	return c, nil
+}
+
+// storeProfileCredential saves c in the credential store under the given
+// profile and points the profile at it instead of a plaintext api_key.
+func storeProfileCredential(doc configDoc, profile string, c *credential) error {
+	store, err := openCredentialStore()
+	if err != nil {
+		return err
+	}
+
+	id := profileCredentialID(profile)
+	store.put(id, c)
+	if err := store.save(); err != nil {
+		return err
+	}
+
+	doc.set(fmt.Sprintf("profiles.%s.credential", profile), id)
+	doc.unset(fmt.Sprintf("profiles.%s.api_key", profile))
+	return nil
+}
+
+// profileCredentialID is the credential store ID of a profile's entry.
+func profileCredentialID(profile string) string {
+	return profileCredentialPrefix + profile
+}
+
+// migratePlaintextKeys moves every plaintext api_key in the config document
+// into the credential store and returns where they came from. The caller is
+// responsible for saving doc.
+func migratePlaintextKeys(doc configDoc) ([]string, error) {
+	var migrated []string
+
+	store, err := openCredentialStore()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range profileNames(doc) {
+		key := profileValue(doc, name, "api_key")
+		if key == "" {
+			continue
+		}
+		id := profileCredentialID(name)
+		store.put(id, &credential{APIKey: key})
+		doc.set(fmt.Sprintf("profiles.%s.credential", name), id)
+		doc.unset(fmt.Sprintf("profiles.%s.api_key", name))
+		migrated = append(migrated, "profile "+name)
+	}
+
+	if raw, ok := doc.get("api_key"); ok && fmt.Sprint(raw) != "" {
+		store.put(globalCredentialID, &credential{APIKey: fmt.Sprint(raw)})
+		doc.set("credential", globalCredentialID)
+		doc.unset("api_key")
+		migrated = append(migrated, "top-level api_key")
+	}
+
+	if len(migrated) == 0 {
+		return nil, nil
+	}
+	return migrated, store.save()
+}
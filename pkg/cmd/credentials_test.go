@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useCredentialStore points the store at a fresh file unlocked by
+// passphrase and drops any cached store.
+func useCredentialStore(t *testing.T, passphrase string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "credentials")
+	t.Setenv("SAPLIY_CREDENTIALS_FILE", path)
+	t.Setenv("SAPLIY_CREDENTIALS_PASSPHRASE", passphrase)
+	t.Setenv("SAPLIY_CREDENTIALS_KEY_FILE", "")
+	unlockedStore = nil
+	t.Cleanup(func() { unlockedStore = nil })
+	return path
+}
+
+func TestCredentialStoreRoundTrip(t *testing.T) {
+	path := useCredentialStore(t, "correct horse")
+
+	store, err := openCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := map[string]*credential{
+		globalCredentialID:        {APIKey: "sk_test_global"},
+		profileCredentialID("ci"): {AccessToken: "at_1", RefreshToken: "rt_1", TokenType: "Bearer", ExpiresAt: expires},
+	}
+	for id, c := range want {
+		store.put(id, c)
+	}
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("store mode = %o, want 600", mode)
+	}
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"sk_test_global", "at_1", "rt_1"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("store file contains %q in plaintext", secret)
+		}
+	}
+
+	unlockedStore = nil
+	store, err = openCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, w := range want {
+		got, ok := store.get(id)
+		if !ok {
+			t.Errorf("get(%q): not found", id)
+			continue
+		}
+		if *got != *w {
+			t.Errorf("get(%q) = %+v, want %+v", id, got, w)
+		}
+	}
+
+	if !store.remove(globalCredentialID) {
+		t.Error("remove of an existing entry = false")
+	}
+	if store.remove(globalCredentialID) {
+		t.Error("remove of a missing entry = true")
+	}
+	if _, ok := store.get(globalCredentialID); ok {
+		t.Error("removed entry is still there")
+	}
+}
+
+func TestCredentialStoreSaveUsesFreshNonce(t *testing.T) {
+	path := useCredentialStore(t, "correct horse")
+	store, err := openCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.put(globalCredentialID, &credential{APIKey: "sk_test_1"})
+
+	var nonces []string
+	for range 2 {
+		if err := store.save(); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		var env credentialEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			t.Fatal(err)
+		}
+		nonces = append(nonces, string(env.Nonce))
+	}
+	if nonces[0] == nonces[1] {
+		t.Error("two saves used the same nonce")
+	}
+}
+
+func TestOpenCredentialStoreErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, path string)
+		wantErr string
+	}{
+		{
+			name: "wrong passphrase",
+			setup: func(t *testing.T, path string) {
+				t.Setenv("SAPLIY_CREDENTIALS_PASSPHRASE", "wrong")
+			},
+			wantErr: "wrong passphrase or key file",
+		},
+		{
+			name: "tampered ciphertext",
+			setup: func(t *testing.T, path string) {
+				rewriteEnvelope(t, path, func(env *credentialEnvelope) { env.Ciphertext[0] ^= 0xff })
+			},
+			wantErr: "cannot decrypt",
+		},
+		{
+			name: "unsupported version",
+			setup: func(t *testing.T, path string) {
+				rewriteEnvelope(t, path, func(env *credentialEnvelope) { env.Version = 99 })
+			},
+			wantErr: "unsupported credential store format",
+		},
+		{
+			name: "not JSON",
+			setup: func(t *testing.T, path string) {
+				os.WriteFile(path, []byte("sk_test_plaintext"), 0o600)
+			},
+			wantErr: "parse",
+		},
+		{
+			name: "empty key file",
+			setup: func(t *testing.T, path string) {
+				keyFile := filepath.Join(t.TempDir(), "key")
+				os.WriteFile(keyFile, []byte("\n"), 0o600)
+				t.Setenv("SAPLIY_CREDENTIALS_KEY_FILE", keyFile)
+			},
+			wantErr: "is empty",
+		},
+		{
+			name: "missing key file",
+			setup: func(t *testing.T, path string) {
+				t.Setenv("SAPLIY_CREDENTIALS_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+			},
+			wantErr: "read credentials key file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useCredentialStore(t, "correct horse")
+			store, err := openCredentialStore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.put(globalCredentialID, &credential{APIKey: "sk_test_1"})
+			if err := store.save(); err != nil {
+				t.Fatal(err)
+			}
+
+			unlockedStore = nil
+			tt.setup(t, path)
+			_, err = openCredentialStore()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("openCredentialStore error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func rewriteEnvelope(t *testing.T, path string, edit func(*credentialEnvelope)) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env credentialEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	edit(&env)
+	if data, err = json.Marshal(env); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCredentialKeyFile(t *testing.T) {
+	useCredentialStore(t, "")
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("  from-key-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SAPLIY_CREDENTIALS_KEY_FILE", keyFile)
+	// The key file wins over the passphrase variable.
+	t.Setenv("SAPLIY_CREDENTIALS_PASSPHRASE", "ignored")
+
+	got, err := credentialPassphrase(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "from-key-file" {
+		t.Errorf("credentialPassphrase = %q, want %q", got, "from-key-file")
+	}
+}
+
+func TestMigratePlaintextKeys(t *testing.T) {
+	useCredentialStore(t, "correct horse")
+	doc := configDoc{
+		"api_key": "sk_test_global",
+		"profiles": map[string]interface{}{
+			"ci":      map[string]interface{}{"api_key": "sk_test_ci"},
+			"staging": map[string]interface{}{"credential": profileCredentialID("staging")},
+		},
+	}
+
+	migrated, err := migratePlaintextKeys(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(migrated, ", "), "profile ci, top-level api_key"; got != want {
+		t.Errorf("migrated = %q, want %q", got, want)
+	}
+
+	wantRefs := map[string]string{
+		"credential":                  globalCredentialID,
+		"profiles.ci.credential":      profileCredentialID("ci"),
+		"profiles.staging.credential": profileCredentialID("staging"),
+	}
+	for key, want := range wantRefs {
+		if got, _ := doc.get(key); got != want {
+			t.Errorf("%s = %v, want %q", key, got, want)
+		}
+	}
+	for _, key := range []string{"api_key", "profiles.ci.api_key"} {
+		if v, ok := doc.get(key); ok {
+			t.Errorf("%s = %v after migration, want it removed", key, v)
+		}
+	}
+
+	unlockedStore = nil
+	store, err := openCredentialStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{globalCredentialID: "sk_test_global", profileCredentialID("ci"): "sk_test_ci"} {
+		if c, ok := store.get(id); !ok || c.APIKey != want {
+			t.Errorf("store entry %q = %+v, want API key %q", id, c, want)
+		}
+	}
+
+	if migrated, err := migratePlaintextKeys(doc); err != nil || migrated != nil {
+		t.Errorf("second migration = %v, %v; want nothing to do", migrated, err)
+	}
+}
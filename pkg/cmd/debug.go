@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -9,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -26,11 +26,9 @@ var debugListenCmd = &cobra.Command{
 	Short: "Listen to real-time event stream via WebSocket",
 	Long: `Connect to Sapliy API and stream events in real-time.
 This is useful for debugging flows and watching events as they happen.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set. Use 'sapliy auth login'.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
 		}
 
 		zone := viper.GetString("current_zone")
@@ -38,97 +36,50 @@ This is useful for debugging flows and watching events as they happen.`,
 			zone, _ = cmd.Flags().GetString("zone")
 		}
 
-		// Determine WS URL (default to localhost:8089 for dev)
-		apiURL := viper.GetString("api_url")
-		wsURL := "ws://localhost:8089/v1/events/stream"
-		if apiURL != "" && !strings.Contains(apiURL, "localhost") {
-			// Production logic would replace https:// with wss://
-			wsURL = strings.Replace(apiURL, "https://", "wss://", 1) + "/v1/events/stream"
-		}
-
-		// Append query params
-		wsURL += fmt.Sprintf("?api_key=%s", apiKey)
-		if zone != "" {
-			wsURL += fmt.Sprintf("&zone=%s", zone)
-		}
-
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		filterType, _ := cmd.Flags().GetString("filter")
 
-		fmt.Printf("🔌 Connecting to %s...\n", wsURL)
-
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-		if err != nil {
-			fmt.Printf("❌ Failed to connect: %v\n", err)
-			return
-		}
-		defer conn.Close()
-
-		fmt.Println("✅ Connected! Streaming events... (Ctrl+C to stop)")
-		fmt.Println(strings.Repeat("─", 60))
+		fmt.Fprintf(stdout, "🔌 Connecting to %s...\n", eventStreamURL(zone))
 
 		// Handle graceful shutdown
-		interrupt := make(chan os.Signal, 1)
-		signal.Notify(interrupt, os.Interrupt)
-
-		done := make(chan struct{})
-
-		go func() {
-			defer close(done)
-			for {
-				_, message, err := conn.ReadMessage()
-				if err != nil {
-					// Check if normal close
-					if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-						fmt.Printf("❌ connection error: %v\n", err)
-					}
-					return
-				}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
 
-				var event map[string]interface{}
-				if err := json.Unmarshal(message, &event); err != nil {
-					continue
-				}
-
-				eventType, _ := event["type"].(string)
+		err := runEventStream(ctx, zone, func(message []byte) {
+			var event map[string]interface{}
+			if err := json.Unmarshal(message, &event); err != nil {
+				return
+			}
 
-				// Apply filter if specified
-				if filterType != "" && !strings.Contains(eventType, filterType) {
-					continue
-				}
+			eventType, _ := event["type"].(string)
 
-				timestamp := time.Now().Format("15:04:05")
+			// Apply filter if specified
+			if filterType != "" && !strings.Contains(eventType, filterType) {
+				return
+			}
 
-				if verbose {
-					prettyJSON, _ := json.MarshalIndent(event, "", "  ")
-					fmt.Printf("[%s] %s\n%s\n\n", timestamp, eventType, string(prettyJSON))
-				} else {
-					// Try to get ID if available
-					id := ""
-					if data, ok := event["data"].(map[string]interface{}); ok {
-						if val, ok := data["id"].(string); ok {
-							id = val
-						}
+			timestamp := time.Now().Format("15:04:05")
+
+			if verbose {
+				prettyJSON, _ := json.MarshalIndent(event, "", "  ")
+				fmt.Fprintf(stdout, "[%s] %s\n%s\n\n", timestamp, eventType, string(prettyJSON))
+			} else {
+				// Try to get ID if available
+				id := ""
+				if data, ok := event["data"].(map[string]interface{}); ok {
+					if val, ok := data["id"].(string); ok {
+						id = val
 					}
-					fmt.Printf("[%s] %-30s  %s\n", timestamp, eventType, id)
 				}
+				fmt.Fprintf(stdout, "[%s] %-30s  %s\n", timestamp, eventType, id)
 			}
-		}()
-
-		select {
-		case <-interrupt:
-			fmt.Println("\n👋 Disconnecting...")
-			err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				return
-			}
-			select {
-			case <-done:
-			case <-time.After(time.Second):
-			}
-		case <-done:
-			fmt.Println("Server closed connection")
+		})
+		if err != nil {
+			return fmt.Errorf("connect to event stream: %w", err)
 		}
+
+		fmt.Fprintln(stdout, "\n👋 Disconnected")
+		return nil
 	},
 }
 
@@ -138,19 +89,18 @@ var debugInspectCmd = &cobra.Command{
 	Use:   "inspect [flow_id]",
 	Short: "Inspect a specific flow execution",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
 		}
 
 		flowID := args[0]
-		fmt.Printf("🔍 Inspecting flow: %s\n", flowID)
-		fmt.Println(strings.Repeat("─", 60))
+		fmt.Fprintf(stdout, "🔍 Inspecting flow: %s\n", flowID)
+		fmt.Fprintln(stdout, strings.Repeat("─", 60))
 
 		// TODO: Implement API call to get flow details
-		fmt.Println("Flow inspection coming soon...")
+		fmt.Fprintln(stdout, "Flow inspection coming soon...")
+		return nil
 	},
 }
 
@@ -159,23 +109,22 @@ var debugReplCmd = &cobra.Command{
 	Short: "Interactive REPL for testing events",
 	Long: `Start an interactive REPL to test events and flows.
 Type event types and JSON data to trigger events interactively.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
 		}
 
 		zone := viper.GetString("current_zone")
 
-		fmt.Println("🎮 Sapliy Debug REPL")
-		fmt.Println("Type 'help' for commands, 'exit' to quit")
-		fmt.Printf("Current zone: %s\n", zone)
-		fmt.Println(strings.Repeat("─", 60))
+		fmt.Fprintln(stdout, "🎮 Sapliy Debug REPL")
+		fmt.Fprintln(stdout, "Type 'help' for commands, 'exit' to quit")
+		fmt.Fprintf(stdout, "Current zone: %s\n", zone)
+		fmt.Fprintln(stdout, strings.Repeat("─", 60))
 
 		scanner := bufio.NewScanner(os.Stdin)
 		for {
-			fmt.Print("sapliy> ")
+			fmt.Fprint(stdout, "sapliy> ")
 			if !scanner.Scan() {
 				break
 			}
@@ -187,18 +136,18 @@ Type event types and JSON data to trigger events interactively.`,
 
 			switch input {
 			case "exit", "quit":
-				fmt.Println("👋 Goodbye!")
-				return
+				fmt.Fprintln(stdout, "👋 Goodbye!")
+				return nil
 			case "help":
-				fmt.Println(`Commands:
+				fmt.Fprintln(stdout, `Commands:
   emit <type> [json]  - Emit an event (e.g., emit payment.created {"amount":100})
   zone <id>           - Switch to a different zone
   status              - Show current configuration
   exit                - Exit the REPL`)
 			case "status":
-				fmt.Printf("API Key: %s...%s\n", apiKey[:8], apiKey[len(apiKey)-4:])
-				fmt.Printf("Zone: %s\n", zone)
-				fmt.Printf("API URL: %s\n", viper.GetString("api_url"))
+				fmt.Fprintf(stdout, "API Key: %s\n", maskSecret(apiKey))
+				fmt.Fprintf(stdout, "Zone: %s\n", zone)
+				fmt.Fprintf(stdout, "API URL: %s\n", viper.GetString("api_url"))
 			default:
 				if strings.HasPrefix(input, "emit ") {
 					parts := strings.SplitN(input[5:], " ", 2)
@@ -207,17 +156,18 @@ Type event types and JSON data to trigger events interactively.`,
 					if len(parts) > 1 {
 						data = parts[1]
 					}
-					fmt.Printf("➡️  Emitting %s: %s\n", eventType, data)
+					fmt.Fprintf(stdout, "➡️  Emitting %s: %s\n", eventType, data)
 					// TODO: Actually emit the event via SDK
 				} else if strings.HasPrefix(input, "zone ") {
 					zone = strings.TrimSpace(input[5:])
 					viper.Set("current_zone", zone)
-					fmt.Printf("✅ Switched to zone: %s\n", zone)
+					fmt.Fprintf(stdout, "✅ Switched to zone: %s\n", zone)
 				} else {
-					fmt.Printf("Unknown command: %s\n", input)
+					fmt.Fprintf(stdout, "Unknown command: %s\n", input)
 				}
 			}
 		}
+		return scanner.Err()
 	},
 }
 
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Kinds of failure a command can report. Each maps to a process exit code so
+// scripts and CI can tell what went wrong without parsing messages.
+const (
+	kindGeneral     = "error"
+	kindValidation  = "validation"
+	kindAuth        = "auth"
+	kindNotFound    = "not_found"
+	kindConflict    = "conflict"
+	kindRateLimited = "rate_limited"
+	kindNetwork     = "network"
+	kindServer      = "server"
+)
+
+// Exit codes, documented in the README.
+var exitCodes = map[string]int{
+	kindGeneral:     1,
+	kindValidation:  2,
+	kindAuth:        3,
+	kindNotFound:    4,
+	kindConflict:    5,
+	kindRateLimited: 6,
+	kindNetwork:     7,
+	kindServer:      8,
+}
+
+// cliError is the error every command returns. Kind selects the exit code;
+// Hint tells the user what to do next.
+type cliError struct {
+	Kind           string `json:"kind"`
+	Message        string `json:"message"`
+	Status         int    `json:"status,omitempty"`
+	Hint           string `json:"hint,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	err error
+}
+
+func (e *cliError) Error() string { return e.Message }
+func (e *cliError) Unwrap() error { return e.err }
+
+func (e *cliError) exitCode() int {
+	if code, ok := exitCodes[e.Kind]; ok {
+		return code
+	}
+	return exitCodes[kindGeneral]
+}
+
+func newCLIError(kind, format string, args ...interface{}) *cliError {
+	err := fmt.Errorf(format, args...)
+	return &cliError{Kind: kind, Message: err.Error(), err: errors.Unwrap(err)}
+}
+
+// validationError reports bad input: arguments, flags or config values.
+func validationError(format string, args ...interface{}) error {
+	return newCLIError(kindValidation, format, args...)
+}
+
+// authError reports missing or rejected credentials.
+func authError(format string, args ...interface{}) error {
+	e := newCLIError(kindAuth, format, args...)
+	e.Hint = "run 'sapliy auth login' or check 'sapliy auth status'"
+	return e
+}
+
+// notFoundError reports a resource that does not exist.
+func notFoundError(format string, args ...interface{}) error {
+	return newCLIError(kindNotFound, format, args...)
+}
+
+// apiError is a non-2xx response from the Sapliy API. It formats like the
+// SDK's errors so both can be classified the same way.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("api error: status=%d body=%s", e.Status, e.Body)
+}
+
+// sdkErrorRe matches the errors the SDK returns for non-2xx responses.
+var sdkErrorRe = regexp.MustCompile(`(?s)api error: status=(\d+) body=(.*)$`)
+
+// classifyError turns any error into a cliError, inferring its kind from API
+// status codes and network failures.
+func classifyError(err error) *cliError {
+	var ce *cliError
+	if errors.As(err, &ce) {
+		return ce
+	}
+
+	msg := err.Error()
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		if m := sdkErrorRe.FindStringSubmatch(msg); m != nil {
+			status, _ := strconv.Atoi(m[1])
+			ae = &apiError{Status: status, Body: m[2]}
+		}
+	}
+	if ae != nil {
+		// Replace the raw "api error: status=... body=..." with the server's
+		// own message.
+		readable := fmt.Sprintf("%s (HTTP %d)", apiErrorMessage(ae), ae.Status)
+		msg = strings.Replace(msg, ae.Error(), readable, 1)
+		e := &cliError{Kind: kindForStatus(ae.Status), Message: msg, Status: ae.Status, err: err}
+		if e.Kind == kindAuth {
+			e.Hint = "run 'sapliy auth login' or check 'sapliy auth status'"
+		}
+		return e
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return &cliError{Kind: kindNetwork, Message: msg, Hint: "check api_url, your connection and proxy settings", err: err}
+	}
+	return &cliError{Kind: kindGeneral, Message: msg, err: err}
+}
+
+func kindForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return kindAuth
+	case status == http.StatusNotFound:
+		return kindNotFound
+	case status == http.StatusConflict || status == http.StatusPreconditionFailed:
+		return kindConflict
+	case status == http.StatusTooManyRequests:
+		return kindRateLimited
+	case status >= 500:
+		return kindServer
+	case status >= 400:
+		return kindValidation
+	}
+	return kindGeneral
+}
+
+// apiErrorMessage extracts a human-readable message from an error body.
+func apiErrorMessage(e *apiError) string {
+	var body struct {
+		Message          string `json:"message"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal([]byte(e.Body), &body) == nil {
+		for _, s := range []string{body.Message, body.ErrorDescription, body.Error} {
+			if s != "" {
+				return s
+			}
+		}
+	}
+	if b := strings.TrimSpace(e.Body); b != "" && len(b) <= 200 {
+		return b
+	}
+	return strings.ToLower(http.StatusText(e.Status))
+}
+
+// withIdempotencyKeyHint attaches the key of a failed mutation to err so the
+// operator can resume it safely.
+func withIdempotencyKeyHint(err error, key string) error {
+	e := classifyError(err)
+	e.IdempotencyKey = key
+	e.Hint = fmt.Sprintf("re-run with --idempotency-key %s to retry safely", key)
+	return e
+}
+
+// printError reports err on stderr: as a structured object for json and
+// yaml output, otherwise as plain text.
+func printError(err error) int {
+	e := classifyError(err)
+
+	if format, _ := outputFormat(); format == outputJSON || format == outputYAML {
+		v := map[string]*cliError{"error": e}
+		if format == outputYAML {
+			var generic interface{}
+			data, _ := json.Marshal(v)
+			json.Unmarshal(data, &generic)
+			out, _ := yaml.Marshal(generic)
+			stderr.Write(out)
+		} else {
+			enc := json.NewEncoder(stderr)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			enc.Encode(v)
+		}
+		return e.exitCode()
+	}
+
+	fmt.Fprintf(stderr, "Error: %s\n", e.Message)
+	if e.IdempotencyKey != "" {
+		fmt.Fprintf(stderr, "   Idempotency-Key: %s\n", e.IdempotencyKey)
+	}
+	if e.Hint != "" {
+		fmt.Fprintf(stderr, "   Hint: %s\n", e.Hint)
+	}
+	return e.exitCode()
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKind string
+		wantCode int
+		wantMsg  string // "" skips the check
+		wantHint bool
+	}{
+		{
+			name:     "cli error passes through",
+			err:      validationError("bad --limit"),
+			wantKind: kindValidation, wantCode: 2, wantMsg: "bad --limit",
+		},
+		{
+			name:     "wrapped cli error",
+			err:      fmt.Errorf("create endpoint: %w", notFoundError("no such event")),
+			wantKind: kindNotFound, wantCode: 4, wantMsg: "no such event",
+		},
+		{
+			name:     "sdk unauthorized",
+			err:      errors.New(`api error: status=401 body={"message":"invalid api key"}`),
+			wantKind: kindAuth, wantCode: 3, wantMsg: "invalid api key (HTTP 401)", wantHint: true,
+		},
+		{
+			name:     "sdk error with context",
+			err:      fmt.Errorf("list events: %w", errors.New(`api error: status=404 body={"error":"not found"}`)),
+			wantKind: kindNotFound, wantCode: 4, wantMsg: "list events: not found (HTTP 404)",
+		},
+		{
+			name:     "api error",
+			err:      &apiError{Status: 409, Body: `{"error_description":"already exists"}`},
+			wantKind: kindConflict, wantCode: 5, wantMsg: "already exists (HTTP 409)",
+		},
+		{
+			name:     "rate limited",
+			err:      &apiError{Status: 429, Body: "slow down"},
+			wantKind: kindRateLimited, wantCode: 6, wantMsg: "slow down (HTTP 429)",
+		},
+		{
+			name:     "bad request",
+			err:      &apiError{Status: 422, Body: ""},
+			wantKind: kindValidation, wantCode: 2, wantMsg: "unprocessable entity (HTTP 422)",
+		},
+		{
+			name:     "server error",
+			err:      &apiError{Status: 502, Body: "<html>bad gateway</html>"},
+			wantKind: kindServer, wantCode: 8,
+		},
+		{
+			name:     "connection refused",
+			err:      &url.Error{Op: "Get", URL: "http://localhost:1", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+			wantKind: kindNetwork, wantCode: 7, wantHint: true,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("send: %w", context.DeadlineExceeded),
+			wantKind: kindNetwork, wantCode: 7, wantHint: true,
+		},
+		{
+			name:     "anything else",
+			err:      errors.New("boom"),
+			wantKind: kindGeneral, wantCode: 1, wantMsg: "boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := classifyError(tt.err)
+			if e.Kind != tt.wantKind {
+				t.Errorf("kind = %q, want %q", e.Kind, tt.wantKind)
+			}
+			if got := e.exitCode(); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantCode)
+			}
+			if tt.wantMsg != "" && e.Message != tt.wantMsg {
+				t.Errorf("message = %q, want %q", e.Message, tt.wantMsg)
+			}
+			if (e.Hint != "") != tt.wantHint {
+				t.Errorf("hint = %q, want hint %v", e.Hint, tt.wantHint)
+			}
+			var ce *cliError
+			if !errors.As(tt.err, &ce) && !errors.Is(e, tt.err) {
+				t.Errorf("classified error does not wrap the original")
+			}
+		})
+	}
+}
+
+func TestKindForStatus(t *testing.T) {
+	tests := map[int]string{
+		200: kindGeneral,
+		400: kindValidation,
+		401: kindAuth,
+		403: kindAuth,
+		404: kindNotFound,
+		409: kindConflict,
+		412: kindConflict,
+		422: kindValidation,
+		429: kindRateLimited,
+		500: kindServer,
+		503: kindServer,
+	}
+	for status, want := range tests {
+		if got := kindForStatus(status); got != want {
+			t.Errorf("kindForStatus(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestExitCodeUnknownKind(t *testing.T) {
+	if got := (&cliError{Kind: "mystery"}).exitCode(); got != exitCodes[kindGeneral] {
+		t.Errorf("exit code of an unknown kind = %d, want %d", got, exitCodes[kindGeneral])
+	}
+}
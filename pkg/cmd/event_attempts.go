@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Every webhook delivery is recorded as an attempt, including the exact
+// request that was sent, so failures can be reproduced against a local
+// server.
+
+// eventDetail is an event together with its delivery history.
+type eventDetail struct {
+	webhookEvent
+	MaxAttempts      int               `json:"max_attempts,omitempty"`
+	NextRetryAt      *time.Time        `json:"next_retry_at,omitempty"`
+	DeliveryAttempts []deliveryAttempt `json:"delivery_attempts"`
+}
+
+// deliveryAttempt is one try at delivering an event to an endpoint.
+// StatusCode is 0 when no response was received; Error then says why.
+type deliveryAttempt struct {
+	Number       int             `json:"attempt"`
+	AttemptedAt  time.Time       `json:"attempted_at"`
+	Endpoint     string          `json:"endpoint"`
+	StatusCode   int             `json:"status_code"`
+	Error        string          `json:"error,omitempty"`
+	LatencyMS    int64           `json:"latency_ms"`
+	ResponseBody string          `json:"response_body,omitempty"`
+	NextRetryAt  *time.Time      `json:"next_retry_at,omitempty"`
+	Request      *attemptRequest `json:"request,omitempty"`
+}
+
+// attemptRequest is the HTTP request sent for an attempt.
+type attemptRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// attemptList is GET /v1/events/{id}/attempts. Like event pages it may be
+// a bare array.
+type attemptList struct {
+	Data []deliveryAttempt `json:"data"`
+}
+
+func (l *attemptList) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		return json.Unmarshal(data, &l.Data)
+	}
+	type list attemptList
+	return json.Unmarshal(data, (*list)(l))
+}
+
+// getEventDetail fetches an event and all of its delivery attempts, oldest
+// first.
+func getEventDetail(ctx context.Context, zone, eventID string) (*eventDetail, error) {
+	q := url.Values{}
+	q.Set("zone_id", zone)
+	path := "/v1/events/" + url.PathEscape(eventID)
+
+	var detail eventDetail
+	if err := apiRequest(ctx, http.MethodGet, path+"?"+q.Encode(), nil, &detail); err != nil {
+		return nil, err
+	}
+	var attempts attemptList
+	if err := apiRequest(ctx, http.MethodGet, path+"/attempts?"+q.Encode(), nil, &attempts); err != nil {
+		return nil, fmt.Errorf("delivery attempts: %w", err)
+	}
+	detail.DeliveryAttempts = attempts.Data
+	slices.SortFunc(detail.DeliveryAttempts, func(a, b deliveryAttempt) int { return a.Number - b.Number })
+	return &detail, nil
+}
+
+// attempt returns delivery attempt n.
+func (d *eventDetail) attempt(n int) (*deliveryAttempt, bool) {
+	for i := range d.DeliveryAttempts {
+		if d.DeliveryAttempts[i].Number == n {
+			return &d.DeliveryAttempts[i], true
+		}
+	}
+	return nil, false
+}
+
+// outcome describes the result of an attempt, e.g. "500 Internal Server
+// Error" or "no response: timeout".
+func (a deliveryAttempt) outcome() string {
+	if a.StatusCode == 0 {
+		return "no response: " + valueOrDash(a.Error)
+	}
+	return fmt.Sprintf("%d %s", a.StatusCode, http.StatusText(a.StatusCode))
+}
+
+func (a deliveryAttempt) latency() time.Duration {
+	return time.Duration(a.LatencyMS) * time.Millisecond
+}
+
+// formatRetry describes a scheduled retry relative to now.
+func formatRetry(at *time.Time, now time.Time) string {
+	if at == nil {
+		return "—"
+	}
+	when := at.Local().Format("Jan 02 15:04:05")
+	if d := at.Sub(now); d > 0 {
+		return fmt.Sprintf("%s (in %s)", when, d.Round(time.Second))
+	}
+	return when + " (due)"
+}
+
+// excerpt collapses whitespace and shortens s for one-line display.
+func excerpt(s string, maxLen int) string {
+	return truncate(strings.Join(strings.Fields(s), " "), maxLen)
+}
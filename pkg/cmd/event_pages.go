@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	fintech "github.com/sapliy/fintech-sdk-go"
+)
+
+// Events are listed newest first with cursor pagination: starting_after
+// continues towards older events from an event ID, ending_before towards
+// newer ones. The SDK only supports offsets, so pages are fetched with
+// apiRequest.
+
+const (
+	defaultEventPageSize = 20
+	maxEventPageSize     = 100
+)
+
+// Webhook delivery states accepted by --status.
+var eventStatuses = []string{"pending", "succeeded", "failed"}
+
+// webhookEvent is an event together with the state of its webhook delivery.
+type webhookEvent struct {
+	fintech.Event
+	Status   string `json:"status,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+// eventListParams selects a range of events.
+type eventListParams struct {
+	ZoneID        string
+	PageSize      int
+	StartingAfter string
+	EndingBefore  string
+	Since         time.Time
+	Until         time.Time
+	Filter        eventFilter
+}
+
+// eventFilter narrows a listing. Each field is sent to the API; filters the
+// server does not report as applied are also applied locally.
+type eventFilter struct {
+	Status      string
+	Type        string
+	Endpoint    string
+	MinAttempts int
+}
+
+// params returns the active filters keyed by their API parameter name.
+func (f eventFilter) params() map[string]string {
+	p := map[string]string{}
+	if f.Status != "" {
+		p["status"] = f.Status
+	}
+	if f.Type != "" {
+		p["type"] = f.Type
+	}
+	if f.Endpoint != "" {
+		p["endpoint"] = f.Endpoint
+	}
+	if f.MinAttempts > 0 {
+		p["min_attempts"] = strconv.Itoa(f.MinAttempts)
+	}
+	return p
+}
+
+// match reports whether e passes the filters named in local.
+func (f eventFilter) match(e webhookEvent, local map[string]bool) bool {
+	switch {
+	case local["status"] && !strings.EqualFold(e.Status, f.Status):
+		return false
+	case local["type"] && !matchPattern(e.Type, f.Type):
+		return false
+	case local["endpoint"] && !strings.Contains(e.Endpoint, f.Endpoint):
+		return false
+	case local["min_attempts"] && e.Attempts < f.MinAttempts:
+		return false
+	}
+	return true
+}
+
+// eventPage is one page of GET /v1/events. AppliedFilters lists the filter
+// parameters the server honored.
+type eventPage struct {
+	Data           []webhookEvent `json:"data"`
+	HasMore        bool           `json:"has_more"`
+	AppliedFilters []string       `json:"applied_filters"`
+
+	// bare is set when the server returned a plain array without has_more.
+	bare bool
+}
+
+// UnmarshalJSON also accepts a bare array, which older servers return.
+func (p *eventPage) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		p.bare = true
+		return json.Unmarshal(data, &p.Data)
+	}
+	type page eventPage
+	return json.Unmarshal(data, (*page)(p))
+}
+
+// more reports whether another page follows. Without has_more, a full page
+// is assumed to continue.
+func (p *eventPage) more(pageSize int) bool {
+	if p.bare {
+		return len(p.Data) >= pageSize
+	}
+	return p.HasMore
+}
+
+func (p eventListParams) query() url.Values {
+	q := url.Values{}
+	q.Set("zone_id", p.ZoneID)
+	q.Set("limit", strconv.Itoa(p.PageSize))
+	if p.StartingAfter != "" {
+		q.Set("starting_after", p.StartingAfter)
+	}
+	if p.EndingBefore != "" {
+		q.Set("ending_before", p.EndingBefore)
+	}
+	if !p.Since.IsZero() {
+		q.Set("created_gte", p.Since.UTC().Format(time.RFC3339))
+	}
+	if !p.Until.IsZero() {
+		q.Set("created_lte", p.Until.UTC().Format(time.RFC3339))
+	}
+	for name, value := range p.Filter.params() {
+		q.Set(name, value)
+	}
+	return q
+}
+
+// listEvents fetches pages of events and hands each to fn as it arrives.
+// With all unset only the first page is fetched. It returns the cursor to
+// continue from, or "" when there are no more events in the range.
+func listEvents(ctx context.Context, p eventListParams, all bool, fn func([]webhookEvent) error) (string, error) {
+	if p.PageSize <= 0 {
+		p.PageSize = defaultEventPageSize
+	}
+	backwards := p.EndingBefore != ""
+
+	var local map[string]bool
+	for {
+		var page eventPage
+		if err := apiRequest(ctx, http.MethodGet, "/v1/events?"+p.query().Encode(), nil, &page); err != nil {
+			return "", err
+		}
+		if local == nil {
+			local = localFilters(p.Filter, page.AppliedFilters, all)
+		}
+		if len(page.Data) == 0 {
+			return "", nil
+		}
+		// Servers that ignore the time bounds still get the right result:
+		// filter here, and stop once the listing has passed the range.
+		events, pastRange := eventsInRange(page.Data, p.Since, p.Until, backwards)
+		if len(local) > 0 {
+			kept := events[:0:0]
+			for _, e := range events {
+				if p.Filter.match(e, local) {
+					kept = append(kept, e)
+				}
+			}
+			events = kept
+		}
+		if err := fn(events); err != nil {
+			return "", err
+		}
+		if pastRange || !page.more(p.PageSize) {
+			return "", nil
+		}
+
+		var cursor string
+		if backwards {
+			cursor = page.Data[0].ID
+			p.EndingBefore = cursor
+		} else {
+			cursor = page.Data[len(page.Data)-1].ID
+			p.StartingAfter = cursor
+		}
+		if !all {
+			return cursor, nil
+		}
+	}
+}
+
+// localFilters returns the filters the server did not apply, and tells the
+// user which filters run where.
+func localFilters(f eventFilter, applied []string, all bool) map[string]bool {
+	params := f.params()
+	if len(params) == 0 {
+		return map[string]bool{}
+	}
+
+	server := map[string]bool{}
+	for _, name := range applied {
+		server[name] = true
+	}
+	local := map[string]bool{}
+	var onServer, locally []string
+	for _, name := range sortedKeys(params) {
+		if server[name] {
+			onServer = append(onServer, name)
+		} else {
+			local[name] = true
+			locally = append(locally, name)
+		}
+	}
+
+	if len(onServer) > 0 {
+		fmt.Fprintf(progress(), "Filtering on the server: %s\n", strings.Join(onServer, ", "))
+	}
+	if len(locally) > 0 {
+		fmt.Fprintf(progress(), "Filtering locally (not supported by the API): %s\n", strings.Join(locally, ", "))
+		if !all {
+			fmt.Fprintln(progress(), "Only the fetched page is filtered; use --all to search every page.")
+		}
+	}
+	return local
+}
+
+// eventsInRange keeps events within [since, until]. pastRange reports that
+// the page already reached events beyond the range in the paging direction.
+func eventsInRange(events []webhookEvent, since, until time.Time, backwards bool) ([]webhookEvent, bool) {
+	if since.IsZero() && until.IsZero() {
+		return events, false
+	}
+	kept := events[:0:0]
+	pastRange := false
+	for _, e := range events {
+		switch {
+		case !since.IsZero() && e.CreatedAt.Before(since):
+			pastRange = pastRange || !backwards
+		case !until.IsZero() && e.CreatedAt.After(until):
+			pastRange = pastRange || backwards
+		default:
+			kept = append(kept, e)
+		}
+	}
+	return kept, pastRange
+}
+
+// parseTimeBound parses --since/--until values: an RFC 3339 timestamp, a
+// date (2006-01-02), or an age such as 90m, 24h or 7d counted back from now.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 24h, 7d, 2024-01-15 or 2024-01-15T10:00:00Z)", value)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "2024-01-15T10:00:00Z", want: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{value: "2024-01-15T10:00:00+02:00", want: time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{value: "2024-01-15", want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "0d", want: now},
+		{value: "24h", want: now.Add(-24 * time.Hour)},
+		{value: "90m", want: now.Add(-90 * time.Minute)},
+		{value: "-1h", wantErr: true},
+		{value: "-2d", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "1w", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "2024-13-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.value, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTimeBound(%q) = %s, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTimeBound(%q): %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeBound(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
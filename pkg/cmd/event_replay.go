@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	fintech "github.com/sapliy/fintech-sdk-go"
+)
+
+// Outcomes of replaying one event.
+const (
+	replayReplayed    = "replayed"
+	replayFailed      = "failed"
+	replaySkipped     = "skipped"
+	replayWouldReplay = "would_replay"
+)
+
+const (
+	defaultReplayConcurrency = 4
+	defaultReplayRate        = 10
+)
+
+// replayOptions bounds how hard a bulk replay hits the API.
+type replayOptions struct {
+	Concurrency int
+	// Rate is the maximum number of replays started per second; 0 means
+	// no limit.
+	Rate float64
+}
+
+// replayEvents replays events with at most opts.Concurrency requests in
+// flight. done is called once per event, from a single goroutine, as each
+// replay finishes. Events not started when ctx is cancelled are reported as
+// skipped.
+func replayEvents(ctx context.Context, client *fintech.Client, zone string, events []webhookEvent, opts replayOptions, done func(replayResult)) {
+	jobs := make(chan webhookEvent)
+	results := make(chan replayResult)
+
+	var wg sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				results <- replayEvent(ctx, client, zone, e)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+
+		var tick <-chan time.Time
+		if opts.Rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i, e := range events {
+			if i > 0 && tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() == nil {
+				select {
+				case jobs <- e:
+					continue
+				case <-ctx.Done():
+				}
+			}
+			results <- replayResult{EventID: e.ID, Type: e.Type, ZoneID: zone, Status: replaySkipped}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for r := range results {
+		done(r)
+	}
+}
+
+// replayEvent replays a single event under its own Idempotency-Key, which is
+// reported so a failed replay can be retried with 'webhooks replay'.
+func replayEvent(ctx context.Context, client *fintech.Client, zone string, e webhookEvent) replayResult {
+	key := newIdempotencyKey()
+	r := replayResult{EventID: e.ID, Type: e.Type, ZoneID: zone, IdempotencyKey: key}
+	if err := client.ReplayEvent(withIdempotencyKey(ctx, key), e.ID, zone); err != nil {
+		r.Status = replayFailed
+		r.Error = classifyError(err).Message
+		return r
+	}
+	r.Status = replayReplayed
+	return r
+}
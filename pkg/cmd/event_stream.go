@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+const maxStreamBackoff = 30 * time.Second
+
+// minStreamUptime is how long a connection must stay up, if it delivers
+// nothing, before a drop is treated as a fresh failure rather than part of
+// a reconnect loop.
+const minStreamUptime = 30 * time.Second
+
+// eventStreamURL returns the WebSocket URL of the event bus stream
+// (default to localhost:8089 for dev).
+func eventStreamURL(zone string) string {
+	apiURL := viper.GetString("api_url")
+	wsURL := "ws://localhost:8089/v1/events/stream"
+	if apiURL != "" && !strings.Contains(apiURL, "localhost") {
+		wsURL = strings.TrimRight(apiURL, "/") + "/v1/events/stream"
+		wsURL = strings.Replace(wsURL, "https://", "wss://", 1)
+		wsURL = strings.Replace(wsURL, "http://", "ws://", 1)
+	}
+
+	if zone != "" {
+		wsURL += "?zone=" + url.QueryEscape(zone)
+	}
+	return wsURL
+}
+
+// dialEventStream opens the event stream with a current session token. A
+// handshake rejected with 401 forces a token refresh and one more attempt.
+func dialEventStream(ctx context.Context, zone string) (*websocket.Conn, error) {
+	ts, err := activeTokenSource()
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := newWebSocketDialer()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, bearer, err := ts.Token(ctx, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		wsURL := eventStreamURL(zone)
+		header := http.Header{"User-Agent": {userAgent()}}
+		if bearer {
+			header.Set("Authorization", "Bearer "+token)
+		} else {
+			sep := "?"
+			if strings.Contains(wsURL, "?") {
+				sep = "&"
+			}
+			wsURL += sep + "api_key=" + url.QueryEscape(token)
+		}
+
+		conn, resp, err := dialer.DialContext(ctx, wsURL, header)
+		if err == nil {
+			return conn, nil
+		}
+		if attempt == 0 && bearer && resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			continue
+		}
+		return nil, err
+	}
+}
+
+// runEventStream keeps the event stream connected until ctx is cancelled,
+// calling handle for every message. Dropped connections are re-established
+// with backoff, re-authenticating each time so long sessions survive token
+// expiry. The backoff is only reset once a connection has delivered a
+// message or stayed up for minStreamUptime, so a server that accepts and
+// immediately closes the stream is not hammered. Only a failure to connect
+// the first time is returned.
+func runEventStream(ctx context.Context, zone string, handle func(message []byte)) error {
+	backoff := time.Second
+	connected := false
+
+	for {
+		conn, err := dialEventStream(ctx, zone)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !connected {
+				return err
+			}
+			fmt.Fprintf(stdout, "❌ Reconnect failed: %v (retrying in %s)\n", err, backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxStreamBackoff)
+			continue
+		}
+
+		if connected {
+			fmt.Fprintln(stdout, "✅ Reconnected")
+		} else {
+			fmt.Fprintln(stdout, "✅ Connected! Streaming events... (Ctrl+C to stop)")
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+		}
+		connected = true
+
+		start := time.Now()
+		delivered := readEventStream(ctx, conn, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if delivered || time.Since(start) >= minStreamUptime {
+			backoff = time.Second
+		}
+		fmt.Fprintf(stdout, "⚠️  Connection lost, reconnecting in %s...\n", backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxStreamBackoff)
+	}
+}
+
+// readEventStream reads messages until the connection drops or ctx is
+// cancelled, in which case the connection is closed cleanly. It reports
+// whether any message was delivered.
+func readEventStream(ctx context.Context, conn *websocket.Conn, handle func(message []byte)) bool {
+	defer conn.Close()
+
+	var delivered atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				// Check if normal close
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+					fmt.Fprintf(stdout, "❌ connection error: %v\n", err)
+				}
+				return
+			}
+			delivered.Store(true)
+			handle(message)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		if err != nil {
+			return delivered.Load()
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	case <-done:
+	}
+	return delivered.Load()
+}
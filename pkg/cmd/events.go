@@ -4,25 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 
-	"github.com/sapliy/fintech-sdk-go"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var eventData string
 var zoneID string
 
+// triggerResult is what 'sapliy trigger' reports in structured output.
+type triggerResult struct {
+	Type   string                 `json:"type"`
+	ZoneID string                 `json:"zone_id"`
+	Data   map[string]interface{} `json:"data"`
+	Status string                 `json:"status"`
+}
+
 var triggerCmd = &cobra.Command{
 	Use:   "trigger [event_type]",
 	Short: "Trigger a mock event for automation flows",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set. Use 'sapliy auth login' or set in config.")
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
 		}
 
 		eventType := args[0]
@@ -30,25 +34,30 @@ var triggerCmd = &cobra.Command{
 		var data map[string]interface{}
 		if eventData != "" {
 			if err := json.Unmarshal([]byte(eventData), &data); err != nil {
-				log.Fatalf("Invalid JSON data: %v", err)
+				return validationError("invalid JSON data: %v", err)
 			}
 		}
 
-		client := fintech.NewClient(apiKey, fintech.WithBaseURL(viper.GetString("api_url")))
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
 
 		// In a real implementation, this would hit a dedicated trigger endpoint
 		// For now, we'll simulate the call
-		fmt.Printf("Triggering event '%s' in zone '%s'...\n", eventType, zoneID)
+		fmt.Fprintf(progress(), "Triggering event '%s' in zone '%s'...\n", eventType, zoneID)
 
 		// Use the new SDK TriggerEvent method
-		err := client.TriggerEvent(context.Background(), eventType, zoneID, data)
+		err = client.TriggerEvent(context.Background(), eventType, zoneID, data)
 
 		if err != nil {
-			fmt.Printf("Failed to trigger event: %v\n", err)
-			return
+			return fmt.Errorf("trigger event: %w", err)
 		}
 
-		fmt.Println("✅ Event triggered successfully! The Flow Runner will process it shortly.")
+		result := triggerResult{Type: eventType, ZoneID: zoneID, Data: data, Status: "triggered"}
+		return printObject(result, func() {
+			fmt.Fprintln(stdout, "✅ Event triggered successfully! The Flow Runner will process it shortly.")
+		})
 	},
 }
 
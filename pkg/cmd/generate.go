@@ -18,7 +18,7 @@ var zoneCmd = &cobra.Command{
 	Use:   "zone [name]",
 	Short: "Generate a new automation zone",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		fileName := fmt.Sprintf("%s.zone.json", strings.ToLower(name))
 
@@ -32,10 +32,10 @@ var zoneCmd = &cobra.Command{
 }`, name, name, name)
 
 		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
-			fmt.Printf("Error creating zone: %v\n", err)
-			return
+			return fmt.Errorf("create zone: %w", err)
 		}
-		fmt.Printf("✅ Generated zone file: %s\n", fileName)
+		fmt.Fprintf(stdout, "✅ Generated zone file: %s\n", fileName)
+		return nil
 	},
 }
 
@@ -43,7 +43,7 @@ var flowCmd = &cobra.Command{
 	Use:   "flow [name]",
 	Short: "Generate a new automation flow",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		fileName := fmt.Sprintf("%s.flow.json", strings.ToLower(name))
 
@@ -60,10 +60,10 @@ var flowCmd = &cobra.Command{
 }`, name, name)
 
 		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
-			fmt.Printf("Error creating flow: %v\n", err)
-			return
+			return fmt.Errorf("create flow: %w", err)
 		}
-		fmt.Printf("✅ Generated flow file: %s\n", fileName)
+		fmt.Fprintf(stdout, "✅ Generated flow file: %s\n", fileName)
+		return nil
 	},
 }
 
@@ -1,15 +1,15 @@
 package cmd
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -25,33 +25,132 @@ This is useful for debugging flows that send webhooks during development.
 Examples:
   sapliy listen                    # Listen to all events
   sapliy listen payment.*          # Listen to payment events only
-  sapliy listen --port 3001        # Use custom port`,
+  sapliy listen --port 3001        # Use custom port
+  sapliy listen --forward-to http://localhost:4242/webhook   # Proxy to your app
+  sapliy listen --stream --forward-to http://localhost:4242/webhook   # No public URL needed
+  sapliy listen --forward-to http://localhost:4242/webhook --inspect   # Inspect at http://localhost:4040
+  sapliy listen --secret whsec_old --secret whsec_new   # During a secret rotation
+  sapliy listen --respond rules.yaml   # Scripted failures, to exercise retries
+
+Deliveries are verified against the versioned X-Sapliy-Signature header
+(t=<timestamp>,v1=<signature>), which signs the timestamp together with the
+body, and rejected if the timestamp is outside --tolerance. Legacy body-only
+signatures are rejected unless --allow-legacy-signatures is set.
+
+With --forward-to, each accepted delivery is re-sent to the local
+application with its body and headers unchanged, and the application's
+response is relayed back to the sender. --forward-secret re-signs it with
+the application's own secret instead.
+
+With --stream, no port is opened and Sapliy does not need to reach this
+machine: events are read from the event stream, signed with webhook_secret
+(or --forward-secret), delivered to --forward-to, and each outcome is
+reported back to the event's delivery history.
+
+Every delivery is saved to ~/.sapliy/captures (unless --no-capture); browse
+them with 'sapliy listen history' and re-send one with 'sapliy listen replay'.
+--inspect serves a local page that shows deliveries live and replays them.
+
+--respond scripts the listener's answers from a YAML or JSON rules file, to
+test how retries and failure alerting react to a flaky receiver. The first
+rule whose type, event_id and fields all match picks the status, body,
+headers and delay; "times: N" limits a rule to the first N attempts of each
+event, after which later rules apply. A matched delivery is not forwarded.
+
+  rules:
+    - name: flaky payments
+      type: payment.*
+      times: 3
+      status: 503
+      headers: {Retry-After: "10"}
+      delay: 2s
+    - fields: {data.amount: "2000"}
+      status: 402
+      body: '{"error": "card declined"}'
+
+Every response sent is shown with its delivery and saved in its capture.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		port, _ := cmd.Flags().GetInt("port")
-		secret := viper.GetString("webhook_secret")
+		verifier, err := listenVerifier(cmd)
+		if err != nil {
+			return err
+		}
+
+		var forwarder *deliveryForwarder
+		if target, _ := cmd.Flags().GetString("forward-to"); target != "" {
+			timeout, _ := cmd.Flags().GetDuration("forward-timeout")
+			secret, _ := cmd.Flags().GetString("forward-secret")
+			registerSecret(secret)
+			if forwarder, err = newDeliveryForwarder(target, secret, timeout); err != nil {
+				return err
+			}
+		}
+
+		var responder *mockResponder
+		if path, _ := cmd.Flags().GetString("respond"); path != "" {
+			if responder, err = loadRespondRules(path); err != nil {
+				return err
+			}
+		}
 
 		eventPattern := "*"
 		if len(args) > 0 {
 			eventPattern = args[0]
 		}
 
+		noCapture, _ := cmd.Flags().GetBool("no-capture")
+		recorder := &captureRecorder{Save: !noCapture}
+		if inspect, _ := cmd.Flags().GetBool("inspect"); inspect {
+			// Replays are re-signed the way deliveries reach the application.
+			resign := webhookSecrets()
+			if forwarder != nil && forwarder.Secret != "" {
+				resign = []string{forwarder.Secret}
+			}
+			inspectPort, _ := cmd.Flags().GetInt("inspect-port")
+			recorder.Inspector = newInspector(resign)
+			inspectURL, err := recorder.Inspector.serve(inspectPort)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "🔍 Inspector: %s\n", inspectURL)
+		}
+		if stream, _ := cmd.Flags().GetBool("stream"); stream {
+			if responder != nil {
+				return validationError("--respond cannot be used with --stream: stream deliveries are not answered by the listener")
+			}
+			return listenStream(forwarder, eventPattern, recorder)
+		}
+
 		green := color.New(color.FgGreen, color.Bold)
 		yellow := color.New(color.FgYellow)
 		red := color.New(color.FgRed)
-		cyan := color.New(color.FgCyan)
 
 		green.Printf("\n🎧 Sapliy Webhook Listener\n")
-		fmt.Println(strings.Repeat("─", 60))
-		fmt.Printf("Listening on: http://localhost:%d\n", port)
-		fmt.Printf("Event filter: %s\n", eventPattern)
-		if secret != "" {
-			fmt.Printf("Signature verification: %s\n", green.Sprint("ENABLED"))
+		fmt.Fprintln(stdout, strings.Repeat("─", 60))
+		fmt.Fprintf(stdout, "Listening on: http://localhost:%d\n", port)
+		fmt.Fprintf(stdout, "Event filter: %s\n", eventPattern)
+		if len(verifier.Secrets) > 0 {
+			mode := fmt.Sprintf("%d secret(s), %s tolerance", len(verifier.Secrets), verifier.Tolerance)
+			if verifier.AllowLegacy {
+				mode += ", legacy signatures allowed"
+			}
+			fmt.Fprintf(stdout, "Signature verification: %s (%s)\n", green.Sprint("ENABLED"), mode)
 		} else {
-			fmt.Printf("Signature verification: %s\n", yellow.Sprint("DISABLED (set SAPLIY_WEBHOOK_SECRET)"))
+			fmt.Fprintf(stdout, "Signature verification: %s\n", yellow.Sprint("DISABLED (set SAPLIY_WEBHOOK_SECRET)"))
+		}
+		if forwarder != nil {
+			resign := ""
+			if forwarder.Secret != "" {
+				resign = " (re-signed with --forward-secret)"
+			}
+			fmt.Fprintf(stdout, "Forwarding to: %s%s\n", forwarder.URL, resign)
+		}
+		if responder != nil {
+			fmt.Fprintf(stdout, "Mock responses: %d rule(s) from %s\n", len(responder.rules), responder.Path)
 		}
-		fmt.Println(strings.Repeat("─", 60))
-		fmt.Println()
+		fmt.Fprintln(stdout, strings.Repeat("─", 60))
+		fmt.Fprintln(stdout)
 
 		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != "POST" {
@@ -67,89 +166,223 @@ Examples:
 				return
 			}
 
-			// Extract headers
-			eventID := r.Header.Get("X-Sapliy-Event-ID")
-			if eventID == "" {
-				eventID = r.Header.Get("X-Webhook-ID") // Fallback to deprecated header
-			}
-
-			eventType := r.Header.Get("X-Sapliy-Event-Type")
-			if eventType == "" {
-				eventType = r.Header.Get("X-Webhook-Event") // Fallback
-			}
-
-			timestamp := r.Header.Get("X-Sapliy-Timestamp")
-			if timestamp == "" {
-				timestamp = r.Header.Get("X-Webhook-Timestamp") // Fallback
-			}
-
-			signature := r.Header.Get("X-Sapliy-Signature")
-			if signature == "" {
-				signature = r.Header.Get("X-Webhook-Signature") // Fallback
-			}
+			headers := readWebhookHeaders(r.Header)
 
 			// Filter by event pattern
-			if eventPattern != "*" && !matchPattern(eventType, eventPattern) {
+			if eventPattern != "*" && !matchPattern(headers.EventType, eventPattern) {
 				w.WriteHeader(http.StatusOK)
 				return
 			}
 
 			// Display webhook
-			fmt.Println()
-			cyan.Printf("📨 Incoming Webhook\n")
-			fmt.Println(strings.Repeat("─", 60))
-			fmt.Printf("Event ID:   %s\n", eventID)
-			fmt.Printf("Event Type: %s\n", eventType)
-			fmt.Printf("Timestamp:  %s\n", timestamp)
+			printDeliveryHeader(headers)
+
+			c := newCapture("http", r.Method, r.URL.RequestURI(), r.Header, body)
 
 			// Verify signature
-			if secret != "" && signature != "" {
-				h := hmac.New(sha256.New, []byte(secret))
-				h.Write(body)
-				expectedSig := hex.EncodeToString(h.Sum(nil))
+			var verifyErr error
+			if len(verifier.Secrets) > 0 {
+				legacy, err := verifier.verify(headers, body, time.Now())
+				verifyErr = err
+				switch {
+				case err != nil:
+					c.Signature, c.SignatureError = verdictInvalid, err.Error()
+					red.Printf("Signature:  ✗ INVALID: %v\n", err)
+					if headers.Signature != "" {
+						red.Printf("  Got:      %s\n", headers.Signature)
+						yellow.Printf("  Diagnose: save the body and run 'sapliy webhooks verify --payload <file> --signature <sig>'\n")
+					}
+				case legacy:
+					c.Signature = verdictLegacy
+					yellow.Printf("Signature:  ✓ VALID (legacy, timestamp not signed)\n")
+				default:
+					c.Signature = verdictValid
+					green.Printf("Signature:  ✓ VALID (%s)\n", signatureVersion)
+				}
+			} else if headers.Signature != "" {
+				c.Signature = verdictUnverified
+				yellow.Printf("Signature:  %s (not verified)\n", headers.Signature)
+			} else {
+				c.Signature = verdictUnsigned
+			}
 
-				if signature == expectedSig {
-					green.Printf("Signature:  ✓ VALID\n")
+			// Answer from the --respond rules, or forward accepted
+			// deliveries to the local application.
+			var reply *listenReply
+			if responder != nil && verifyErr == nil {
+				reply = responder.respond(headers, body)
+			}
+			if forwarder != nil && verifyErr == nil && reply == nil {
+				forwarded, err := forwarder.forward(r.Context(), r.Header, body)
+				printForwardOutcome(forwarded, err)
+				c.setForward(forwarder.URL, forwarded, err)
+				if err != nil {
+					reply = jsonReply(http.StatusBadGateway, map[string]string{"error": "forward to local application: " + err.Error()})
 				} else {
-					red.Printf("Signature:  ✗ INVALID\n")
-					red.Printf("  Expected: %s\n", expectedSig)
-					red.Printf("  Got:      %s\n", signature)
+					reply = forwarded.reply()
 				}
-			} else if signature != "" {
-				yellow.Printf("Signature:  %s (not verified)\n", signature)
 			}
-
-			fmt.Println()
-			fmt.Println("Payload:")
-			fmt.Println(strings.Repeat("─", 60))
-
-			// Pretty print JSON
-			var payload map[string]interface{}
-			if err := json.Unmarshal(body, &payload); err == nil {
-				prettyJSON, _ := json.MarshalIndent(payload, "", "  ")
-				fmt.Println(string(prettyJSON))
-			} else {
-				fmt.Println(string(body))
+			switch {
+			case verifyErr != nil:
+				// Reject like a real receiver would, so the sender sees it.
+				reply = jsonReply(http.StatusBadRequest, map[string]string{"error": verifyErr.Error()})
+			case reply == nil:
+				reply = jsonReply(http.StatusOK, map[string]string{"status": "received"})
 			}
+			c.setResponse(reply)
+			printResponse(c.Response)
+			recorder.record(c)
 
-			fmt.Println(strings.Repeat("─", 60))
-			fmt.Println()
-
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"received"}`))
+			printDeliveryPayload(body)
+			reply.write(w)
 		})
 
 		addr := fmt.Sprintf(":%d", port)
 		green.Printf("✓ Server started successfully\n")
-		fmt.Printf("Press Ctrl+C to stop\n\n")
+		fmt.Fprintf(stdout, "Press Ctrl+C to stop\n\n")
 
 		if err := http.ListenAndServe(addr, nil); err != nil {
-			red.Printf("❌ Failed to start server: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("start server: %w", err)
 		}
+		return nil
 	},
 }
 
+// listenStream runs 'sapliy listen --stream' until interrupted.
+func listenStream(forwarder *deliveryForwarder, eventPattern string, recorder *captureRecorder) error {
+	if forwarder == nil {
+		e := newCLIError(kindValidation, "--stream requires --forward-to")
+		e.Hint = "pass the URL of your local webhook handler, e.g. --forward-to http://localhost:4242/webhook"
+		return e
+	}
+	if _, err := requireAPIKey(); err != nil {
+		return err
+	}
+	zone, err := webhookZone()
+	if err != nil {
+		return err
+	}
+
+	// Deliveries are signed here, so --forward-secret replaces
+	// webhook_secret instead of re-signing.
+	secrets := webhookSecrets()
+	if forwarder.Secret != "" {
+		secrets = []string{forwarder.Secret}
+		forwarder.Secret = ""
+	}
+	if len(secrets) == 0 {
+		e := newCLIError(kindValidation, "no signing secret")
+		e.Hint = "pass --forward-secret or run 'sapliy config set webhook_secret <secret>'"
+		return e
+	}
+
+	l := &streamListener{Zone: zone, Pattern: eventPattern, Secrets: secrets, Forwarder: forwarder, Recorder: recorder}
+	l.describe()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := l.run(ctx); err != nil {
+		return fmt.Errorf("connect to event stream: %w", err)
+	}
+	fmt.Fprintln(stdout, "\n👋 Disconnected")
+	return nil
+}
+
+// printDeliveryHeader starts the display of a delivery.
+func printDeliveryHeader(h webhookHeaders) {
+	fmt.Fprintln(stdout)
+	color.New(color.FgCyan).Printf("📨 Incoming Webhook\n")
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+	fmt.Fprintf(stdout, "Event ID:   %s\n", h.EventID)
+	fmt.Fprintf(stdout, "Event Type: %s\n", h.EventType)
+	fmt.Fprintf(stdout, "Timestamp:  %s\n", h.Timestamp)
+}
+
+// printForwardOutcome shows the local application's response to a
+// forwarded delivery.
+func printForwardOutcome(res *forwardResult, err error) {
+	green := color.New(color.FgGreen, color.Bold)
+	red := color.New(color.FgRed)
+	switch {
+	case err != nil:
+		red.Printf("Forwarded:  ✗ %v\n", err)
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		green.Printf("Forwarded:  %d %s in %s\n", res.StatusCode, http.StatusText(res.StatusCode), res.Latency.Round(time.Millisecond))
+	default:
+		red.Printf("Forwarded:  %d %s in %s\n", res.StatusCode, http.StatusText(res.StatusCode), res.Latency.Round(time.Millisecond))
+		if len(res.Body) > 0 {
+			red.Printf("  Response: %s\n", excerpt(string(res.Body), 200))
+		}
+	}
+}
+
+// printResponse shows the response sent for a delivery.
+func printResponse(r *captureResponse) {
+	c := color.New(color.FgGreen)
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		c = color.New(color.FgRed)
+	}
+	c.Printf("Responded:  %s\n", r.describe())
+}
+
+// printCaptureSaved saves a capture and shows its ID for replay.
+func printCaptureSaved(c *capture) {
+	if err := saveCapture(c); err != nil {
+		color.New(color.FgYellow).Printf("Capture:    not saved: %v\n", err)
+		return
+	}
+	fmt.Fprintf(stdout, "Capture:    %s (replay with 'sapliy listen replay %s')\n", c.ID, c.ID)
+}
+
+// printDeliveryPayload ends the display of a delivery with its body,
+// pretty-printed if it is JSON.
+func printDeliveryPayload(body []byte) {
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, "Payload:")
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		prettyJSON, _ := json.MarshalIndent(payload, "", "  ")
+		fmt.Fprintln(stdout, string(prettyJSON))
+	} else {
+		fmt.Fprintln(stdout, string(body))
+	}
+
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+	fmt.Fprintln(stdout)
+}
+
+// listenVerifier builds the delivery verifier from the flags, falling back
+// to the configured webhook settings.
+func listenVerifier(cmd *cobra.Command) (deliveryVerifier, error) {
+	v := deliveryVerifier{
+		Secrets:     webhookSecrets(),
+		Tolerance:   viper.GetDuration("webhook_tolerance"),
+		AllowLegacy: viper.GetBool("webhook_legacy_signatures"),
+	}
+	if cmd.Flags().Changed("secret") {
+		values, _ := cmd.Flags().GetStringArray("secret")
+		v.Secrets = nil
+		for _, value := range values {
+			v.Secrets = append(v.Secrets, splitSecrets(value)...)
+		}
+		for _, secret := range v.Secrets {
+			registerSecret(secret)
+		}
+	}
+	if cmd.Flags().Changed("tolerance") {
+		v.Tolerance, _ = cmd.Flags().GetDuration("tolerance")
+	}
+	if cmd.Flags().Changed("allow-legacy-signatures") {
+		v.AllowLegacy, _ = cmd.Flags().GetBool("allow-legacy-signatures")
+	}
+	if v.Tolerance <= 0 {
+		return v, validationError("--tolerance must be positive")
+	}
+	return v, nil
+}
+
 // matchPattern checks if event type matches the pattern (supports * wildcard)
 func matchPattern(eventType, pattern string) bool {
 	if pattern == "*" {
@@ -168,5 +401,15 @@ func matchPattern(eventType, pattern string) bool {
 func init() {
 	rootCmd.AddCommand(listenCmd)
 	listenCmd.Flags().IntP("port", "p", 3000, "Port to listen on")
-	viper.BindEnv("webhook_secret", "SAPLIY_WEBHOOK_SECRET")
+	listenCmd.Flags().StringArray("secret", nil, "Signing secret; repeat during a rotation (overrides webhook_secret)")
+	listenCmd.Flags().Duration("tolerance", defaultSignatureTolerance, "Maximum clock difference for delivery timestamps (overrides webhook_tolerance)")
+	listenCmd.Flags().Bool("stream", false, "Receive events over the event stream instead of an HTTP port (requires --forward-to)")
+	listenCmd.Flags().String("forward-to", "", "Forward accepted deliveries to this local URL and relay its response")
+	listenCmd.Flags().Duration("forward-timeout", defaultForwardTimeout, "How long to wait for the local application")
+	listenCmd.Flags().String("forward-secret", "", "Re-sign forwarded deliveries with this secret")
+	listenCmd.Flags().Bool("inspect", false, "Serve a web inspector for deliveries on localhost")
+	listenCmd.Flags().Int("inspect-port", defaultInspectPort, "Port for the web inspector")
+	listenCmd.Flags().String("respond", "", "Answer deliveries from the rules in this YAML or JSON file")
+	listenCmd.Flags().Bool("no-capture", false, "Don't save deliveries to ~/.sapliy/captures")
+	listenCmd.Flags().Bool("allow-legacy-signatures", false, "Accept legacy signatures of the body alone (overrides webhook_legacy_signatures)")
 }
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultForwardTimeout bounds how long 'sapliy listen' waits for the local
+// application, well under the time Sapliy waits for a response.
+const defaultForwardTimeout = 10 * time.Second
+
+// maxForwardResponse caps the response body relayed back to the sender.
+const maxForwardResponse = 1 << 20
+
+// hopHeaders apply to a single connection and are not forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length",
+}
+
+// deliveryForwarder re-sends deliveries to a local application. If Secret
+// is set, deliveries are re-signed with it so the application can use its
+// own secret; otherwise the original signature is passed through.
+type deliveryForwarder struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+// forwardResult is the local application's response to a forwarded
+// delivery.
+type forwardResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Latency    time.Duration
+}
+
+func newDeliveryForwarder(target, secret string, timeout time.Duration) (*deliveryForwarder, error) {
+	if err := validateEndpointURL(target); err != nil {
+		return nil, err
+	}
+	client, err := relayHTTPClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &deliveryForwarder{URL: target, Secret: secret, client: client}, nil
+}
+
+// forward POSTs body with the delivery's headers to the application.
+func (f *deliveryForwarder) forward(ctx context.Context, header http.Header, body []byte) (*forwardResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	removeHopHeaders(req.Header)
+	keepUserAgent(req.Header)
+	if f.Secret != "" {
+		// Keep the signed timestamp so the delivery is otherwise unchanged.
+		at := time.Now()
+		if ts, ok := deliveryTimestamp(readWebhookHeaders(header)); ok {
+			at = ts
+		}
+		req.Header.Del("X-Webhook-Signature")
+		signDelivery(req.Header, []string{f.Secret}, body, at)
+	}
+
+	start := time.Now()
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxForwardResponse))
+	if err != nil {
+		return nil, err
+	}
+	removeHopHeaders(resp.Header)
+	return &forwardResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       respBody,
+		Latency:    time.Since(start),
+	}, nil
+}
+
+// reply relays the application's response back to the sender.
+func (r *forwardResult) reply() *listenReply {
+	return &listenReply{Status: r.StatusCode, Header: r.Header, Body: r.Body, Source: replyApplication}
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// deliveryTimestamp returns when a delivery was signed: t= of a versioned
+// signature, else the timestamp header.
+func deliveryTimestamp(h webhookHeaders) (time.Time, bool) {
+	if isVersionedSignature(h.Signature) {
+		if sig, err := parseSignatureHeader(h.Signature); err == nil {
+			if secs, err := strconv.ParseInt(sig.Timestamp, 10, 64); err == nil {
+				return time.Unix(secs, 0), true
+			}
+		}
+	}
+	if h.Timestamp == "" {
+		return time.Time{}, false
+	}
+	ts, err := parseSignatureTimestamp(h.Timestamp)
+	return ts, err == nil
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// captureReplayResult is the outcome of 'sapliy listen replay'.
+type captureReplayResult struct {
+	CaptureID    string `json:"capture_id"`
+	URL          string `json:"url"`
+	Resigned     bool   `json:"resigned,omitempty"`
+	StatusCode   int    `json:"status_code"`
+	LatencyMS    int64  `json:"latency_ms"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+var listenHistoryCmd = &cobra.Command{
+	Use:   "history [capture_id]",
+	Short: "Browse deliveries captured by 'sapliy listen'",
+	Long: `List the deliveries 'sapliy listen' saved to ~/.sapliy/captures, newest
+first, or show one capture in full: the exact request, the signature verdict,
+the local application's response and the response sent back.`,
+	Example: `  sapliy listen history
+  sapliy listen history --type 'payment.*' --failed
+  sapliy listen history --signature invalid --since 1h
+  sapliy listen history cap_19a2f0c3b2e`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			c, err := findCapture(args[0])
+			if err != nil {
+				return err
+			}
+			return printObject(c, func() { printCapture(c) })
+		}
+
+		pattern, _ := cmd.Flags().GetString("type")
+		eventID, _ := cmd.Flags().GetString("event")
+		verdict, _ := cmd.Flags().GetString("signature")
+		failed, _ := cmd.Flags().GetBool("failed")
+		sinceArg, _ := cmd.Flags().GetString("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if limit < 0 {
+			return validationError("--limit must not be negative")
+		}
+		verdicts := []string{verdictValid, verdictLegacy, verdictInvalid, verdictUnverified, verdictUnsigned, verdictSigned}
+		if verdict != "" && !slices.Contains(verdicts, verdict) {
+			return validationError("invalid --signature %q (use %s)", verdict, strings.Join(verdicts, ", "))
+		}
+		since, err := parseTimeBound(sinceArg, time.Now())
+		if err != nil {
+			return validationError("--since: %v", err)
+		}
+
+		all, err := loadCaptures()
+		if err != nil {
+			return fmt.Errorf("read captures: %w", err)
+		}
+		var captures []capture
+		for _, c := range all {
+			switch {
+			case pattern != "" && !matchPattern(c.EventType, pattern),
+				eventID != "" && c.EventID != eventID,
+				verdict != "" && c.Signature != verdict,
+				failed && !c.failed(),
+				!since.IsZero() && c.ReceivedAt.Before(since):
+				continue
+			}
+			captures = append(captures, c)
+			if limit > 0 && len(captures) == limit {
+				break
+			}
+		}
+
+		return printList(captures, []column[capture]{
+			{"ID", func(c capture) string { return c.ID }},
+			{"RECEIVED", func(c capture) string { return c.ReceivedAt.Format(time.RFC3339) }},
+			{"TYPE", func(c capture) string { return c.EventType }},
+			{"EVENT", func(c capture) string { return c.EventID }},
+			{"SIGNATURE", func(c capture) string { return c.Signature }},
+			{"FORWARD", func(c capture) string { return c.forwardOutcome() }},
+			{"RESPONSE", func(c capture) string { return c.responseOutcome() }},
+		}, func() {
+			if len(captures) == 0 {
+				fmt.Fprintln(stdout, "No captured deliveries match.")
+				if len(all) == 0 {
+					fmt.Fprintln(stdout, "Deliveries are captured while 'sapliy listen' runs.")
+				}
+				return
+			}
+			fmt.Fprintf(stdout, "%-22s %-16s %-26s %-30s %-11s %-8s %s\n", "ID", "RECEIVED", "TYPE", "EVENT", "SIGNATURE", "FORWARD", "RESPONSE")
+			fmt.Fprintln(stdout, strings.Repeat("─", 125))
+			for _, c := range captures {
+				fmt.Fprintf(stdout, "%-22s %-16s %-26s %-30s %-11s %-8s %s\n",
+					c.ID, c.ReceivedAt.Local().Format("Jan 02 15:04:05"), truncate(valueOrDash(c.EventType), 26),
+					truncate(valueOrDash(c.EventID), 30), c.Signature, c.forwardOutcome(), c.responseOutcome())
+			}
+		})
+	},
+}
+
+var listenReplayCmd = &cobra.Command{
+	Use:   "replay <capture_id>",
+	Short: "Re-send a captured delivery to a local URL",
+	Long: `Re-send a delivery captured by 'sapliy listen' with its exact method,
+headers and body, so a handler can be tested again without triggering the
+event upstream. It goes to --to, or to the URL it was forwarded to.
+
+The captured signature keeps its original timestamp, so a receiver that
+enforces a tolerance rejects it after a few minutes. --resign signs it
+again with the current time and webhook_secret (or --secret).`,
+	Example: `  sapliy listen replay cap_19a2f0c3b2e
+  sapliy listen replay cap_19a2f0c3b2e --to http://localhost:4242/webhook --resign`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := findCapture(args[0])
+		if err != nil {
+			return err
+		}
+		to, _ := cmd.Flags().GetString("to")
+		target, err := replayTarget(c, to)
+		if err != nil {
+			return err
+		}
+
+		resign, _ := cmd.Flags().GetBool("resign")
+		var secrets []string
+		if resign {
+			secrets = webhookSecrets()
+			if secret, _ := cmd.Flags().GetString("secret"); secret != "" {
+				secrets = splitSecrets(secret)
+			}
+			if len(secrets) == 0 {
+				e := newCLIError(kindValidation, "no signing secret")
+				e.Hint = "pass --secret or run 'sapliy config set webhook_secret <secret>'"
+				return e
+			}
+			for _, secret := range secrets {
+				registerSecret(secret)
+			}
+		}
+
+		fmt.Fprintf(progress(), "🔁 Replaying %s (%s) to %s\n", c.ID, valueOrDash(c.EventType), target)
+		result, err := replayCapture(c, target, secrets)
+		if err != nil {
+			return err
+		}
+		err = printObject(result, func() {
+			fmt.Fprintf(stdout, "Response:   %d %s in %dms\n", result.StatusCode, http.StatusText(result.StatusCode), result.LatencyMS)
+			if result.ResponseBody != "" {
+				fmt.Fprintln(stdout, strings.Repeat("─", 60))
+				fmt.Fprintln(stdout, truncate(result.ResponseBody, 2000))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if result.StatusCode < 200 || result.StatusCode >= 300 {
+			e := newCLIError(kindGeneral, "receiver responded %d %s", result.StatusCode, http.StatusText(result.StatusCode))
+			if !resign && c.Signature != verdictUnsigned {
+				e.Hint = "if the receiver rejected the original timestamp, pass --resign"
+			}
+			return e
+		}
+		return nil
+	},
+}
+
+// replayTarget returns where to replay c: to, or the URL it was forwarded
+// to.
+func replayTarget(c *capture, to string) (string, error) {
+	if to == "" && c.Forward != nil {
+		to = c.Forward.URL
+	}
+	if to == "" {
+		e := newCLIError(kindValidation, "capture %s was not forwarded anywhere", c.ID)
+		e.Hint = "pass --to with the URL of your webhook handler"
+		return "", e
+	}
+	return to, validateEndpointURL(to)
+}
+
+// replayCapture re-sends c to target with its exact method, headers and
+// body. If secrets are given, it is first signed again with the current
+// time.
+func replayCapture(c *capture, target string, secrets []string) (*captureReplayResult, error) {
+	method := c.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(c.Body))
+	if err != nil {
+		return nil, validationError("invalid URL %q: %v", target, err)
+	}
+	req.Header = c.Headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	removeHopHeaders(req.Header)
+	keepUserAgent(req.Header)
+	req.Header.Del("Host")
+	if len(secrets) > 0 {
+		req.Header.Del("X-Webhook-Signature")
+		req.Header.Del("X-Webhook-Timestamp")
+		signDelivery(req.Header, secrets, c.Body, time.Now())
+	}
+
+	client, err := relayHTTPClient(requestTimeout())
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		e := newCLIError(kindNetwork, "replay to %s: %v", target, err)
+		e.Hint = "check that the receiver is running and reachable"
+		return nil, e
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	return &captureReplayResult{
+		CaptureID:    c.ID,
+		URL:          target,
+		Resigned:     len(secrets) > 0,
+		StatusCode:   resp.StatusCode,
+		LatencyMS:    time.Since(start).Milliseconds(),
+		ResponseBody: string(respBody),
+	}, nil
+}
+
+// failed reports whether forwarding the capture failed.
+func (c capture) failed() bool {
+	return c.Forward != nil && (c.Forward.Error != "" || c.Forward.StatusCode < 200 || c.Forward.StatusCode >= 300)
+}
+
+// printCapture shows a capture in full.
+func printCapture(c *capture) {
+	fmt.Fprintf(stdout, "📥 %s: %s received %s via %s\n", c.ID, valueOrDash(c.EventType),
+		c.ReceivedAt.Local().Format("Jan 02 15:04:05"), c.Source)
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+	fmt.Fprintf(stdout, "Event ID:   %s\n", valueOrDash(c.EventID))
+	signature := c.Signature
+	if c.SignatureError != "" {
+		signature += ": " + c.SignatureError
+	}
+	fmt.Fprintf(stdout, "Signature:  %s\n", signature)
+	if f := c.Forward; f != nil {
+		if f.Error != "" {
+			fmt.Fprintf(stdout, "Forwarded:  %s: %s\n", f.URL, f.Error)
+		} else {
+			fmt.Fprintf(stdout, "Forwarded:  %s: %d %s in %dms\n", f.URL, f.StatusCode, http.StatusText(f.StatusCode), f.LatencyMS)
+		}
+	}
+	if c.Response != nil {
+		fmt.Fprintf(stdout, "Responded:  %s\n", c.Response.describe())
+	}
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+	fmt.Fprintf(stdout, "%s %s\n", valueOrDash(c.Method), valueOrDash(c.Path))
+	for _, name := range sortedKeys(c.Headers) {
+		for _, v := range c.Headers[name] {
+			fmt.Fprintf(stdout, "%s: %s\n", name, v)
+		}
+	}
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, string(c.Body))
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+	switch {
+	case c.Response != nil && c.Response.Body != "":
+		fmt.Fprintln(stdout, "Response:")
+		fmt.Fprintln(stdout, c.Response.Body)
+	case c.Forward != nil && c.Forward.ResponseBody != "":
+		fmt.Fprintln(stdout, "Response:")
+		fmt.Fprintln(stdout, c.Forward.ResponseBody)
+	}
+	if !showSecrets {
+		fmt.Fprintln(progress(), "\nSignatures are masked; pass --show-secrets to see them.")
+	}
+}
+
+func init() {
+	listenCmd.AddCommand(listenHistoryCmd)
+	listenCmd.AddCommand(listenReplayCmd)
+
+	listenHistoryCmd.Flags().String("type", "", "Only event types matching this pattern, e.g. 'payment.*'")
+	listenHistoryCmd.Flags().String("event", "", "Only deliveries of this event ID")
+	listenHistoryCmd.Flags().String("signature", "", "Only this signature verdict: valid, legacy, invalid, unverified, unsigned or signed")
+	listenHistoryCmd.Flags().Bool("failed", false, "Only deliveries the local application rejected or did not answer")
+	listenHistoryCmd.Flags().String("since", "", "Only deliveries received after this time (RFC 3339 or a duration like 1h)")
+	listenHistoryCmd.Flags().Int("limit", 20, "Maximum number of captures to list (0 for all)")
+
+	listenReplayCmd.Flags().String("to", "", "URL to send the delivery to (default: where it was forwarded)")
+	listenReplayCmd.Flags().Bool("resign", false, "Sign again with the current time")
+	listenReplayCmd.Flags().String("secret", "", "Secret for --resign (default: webhook_secret)")
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 'sapliy listen --inspect' serves a local page, ui/inspector.html, that
+// shows deliveries as they arrive and can replay them. New captures are
+// pushed to the page with server-sent events.
+
+const (
+	defaultInspectPort = 4040
+	// inspectorBacklog is how many captures the inspector keeps in memory.
+	inspectorBacklog = 200
+)
+
+// captureRecorder saves captures and publishes them to the inspector.
+type captureRecorder struct {
+	Save      bool
+	Inspector *inspector
+}
+
+func (r *captureRecorder) record(c *capture) {
+	if r.Save {
+		printCaptureSaved(c)
+	}
+	if r.Inspector != nil {
+		r.Inspector.publish(c)
+	}
+}
+
+// inspector serves the inspector page and its API.
+type inspector struct {
+	// resign are the secrets replays are signed with when asked to.
+	resign []string
+
+	mu       sync.Mutex
+	captures []*capture
+	clients  map[chan []byte]struct{}
+}
+
+func newInspector(resign []string) *inspector {
+	in := &inspector{resign: resign, clients: map[chan []byte]struct{}{}}
+	saved, err := loadCaptures()
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: could not load earlier captures: %v\n", err)
+	}
+	for i := min(len(saved), inspectorBacklog) - 1; i >= 0; i-- {
+		in.captures = append(in.captures, &saved[i])
+	}
+	return in
+}
+
+// publish adds c to the backlog and pushes it to connected pages. Slow
+// pages miss updates rather than block the listener.
+func (in *inspector) publish(c *capture) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.captures = append(in.captures, c)
+	if len(in.captures) > inspectorBacklog {
+		in.captures = in.captures[len(in.captures)-inspectorBacklog:]
+	}
+	for ch := range in.clients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+func (in *inspector) find(id string) *capture {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for _, c := range in.captures {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// serve starts the inspector on localhost:port in the background.
+func (in *inspector) serve(port int) (string, error) {
+	page, err := fs.ReadFile(content, "ui/inspector.html")
+	if err != nil {
+		return "", err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("GET /api/captures", in.handleList)
+	mux.HandleFunc("GET /api/stream", in.handleStream)
+	mux.HandleFunc("POST /api/captures/{id}/replay", in.handleReplay)
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", fmt.Errorf("start inspector: %w", err)
+	}
+	go http.Serve(ln, localOnly(mux))
+	return "http://" + ln.Addr().String(), nil
+}
+
+// localOnly rejects requests addressed to another host name, so a web page
+// cannot reach the inspector through DNS rebinding, and cross-site
+// replays, which must carry a header a form cannot set.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host != "localhost" && host != "127.0.0.1" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Header.Get("X-Sapliy-Inspector") == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (in *inspector) handleList(w http.ResponseWriter, r *http.Request) {
+	in.mu.Lock()
+	captures := make([]*capture, len(in.captures))
+	copy(captures, in.captures)
+	in.mu.Unlock()
+	writeInspectorJSON(w, http.StatusOK, captures)
+}
+
+func (in *inspector) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan []byte, 16)
+	in.mu.Lock()
+	in.clients[ch] = struct{}{}
+	in.mu.Unlock()
+	defer func() {
+		in.mu.Lock()
+		delete(in.clients, ch)
+		in.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			fmt.Fprintf(w, "event: capture\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (in *inspector) handleReplay(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		To     string `json:"to"`
+		Resign bool   `json:"resign"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeInspectorError(w, validationError("invalid request: %v", err))
+			return
+		}
+	}
+	c := in.find(r.PathValue("id"))
+	if c == nil {
+		writeInspectorError(w, notFoundError("capture %q not found", r.PathValue("id")))
+		return
+	}
+	target, err := replayTarget(c, strings.TrimSpace(req.To))
+	if err != nil {
+		writeInspectorError(w, err)
+		return
+	}
+	var secrets []string
+	if req.Resign {
+		if len(in.resign) == 0 {
+			writeInspectorError(w, validationError("no signing secret to re-sign with; set webhook_secret"))
+			return
+		}
+		secrets = in.resign
+	}
+	result, err := replayCapture(c, target, secrets)
+	if err != nil {
+		writeInspectorError(w, err)
+		return
+	}
+	fmt.Fprintf(stdout, "🔁 Replayed %s to %s from the inspector: %d %s\n",
+		c.ID, target, result.StatusCode, http.StatusText(result.StatusCode))
+	writeInspectorJSON(w, http.StatusOK, result)
+}
+
+func writeInspectorJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeInspectorError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var ce *cliError
+	if errors.As(err, &ce) {
+		switch ce.Kind {
+		case kindValidation:
+			status = http.StatusBadRequest
+		case kindNotFound:
+			status = http.StatusNotFound
+		case kindNetwork:
+			status = http.StatusBadGateway
+		}
+	}
+	writeInspectorJSON(w, status, map[string]string{"error": err.Error()})
+}
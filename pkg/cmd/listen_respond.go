@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// A --respond file scripts the listener's responses, to see how Sapliy's
+// retries and alerting react to a failing receiver:
+//
+//	rules:
+//	  - name: flaky payments
+//	    type: payment.*
+//	    times: 3          # only the first 3 attempts of each event
+//	    status: 503
+//	    headers: {Retry-After: "10"}
+//	    delay: 2s
+//	  - fields: {data.amount: "2000"}
+//	    status: 402
+//	    body: '{"error": "card declined"}'
+//
+// The first rule that matches answers the delivery; it is not forwarded.
+// A rule whose times are used up no longer matches, so later attempts fall
+// through to the next rule or to the normal response.
+
+// Sources of a listener reply.
+const (
+	replyListener    = "listener"
+	replyRule        = "rule"
+	replyApplication = "application"
+)
+
+// respondFile is the layout of a --respond file.
+type respondFile struct {
+	Rules []respondRule `yaml:"rules"`
+}
+
+// respondRule is one rule of a --respond file. Every condition that is set
+// must match.
+type respondRule struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	EventID string            `yaml:"event_id"`
+	Fields  map[string]string `yaml:"fields"`
+	Times   int               `yaml:"times"`
+	Status  int               `yaml:"status"`
+	Body    string            `yaml:"body"`
+	Headers map[string]string `yaml:"headers"`
+	Delay   string            `yaml:"delay"`
+
+	delay time.Duration
+}
+
+// mockResponder picks scripted responses for deliveries.
+type mockResponder struct {
+	Path  string
+	rules []respondRule
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// listenReply is a response 'sapliy listen' sends to a delivery.
+type listenReply struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Delay  time.Duration
+	Source string
+	// Rule and Note explain a scripted response, e.g. "attempt 2 of 3".
+	Rule string
+	Note string
+}
+
+func loadRespondRules(path string) (*mockResponder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, validationError("read --respond file: %v", err)
+	}
+	var doc respondFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, validationError("parse %s: %v", path, err)
+	}
+	if len(doc.Rules) == 0 {
+		return nil, validationError("%s has no rules", path)
+	}
+
+	for i := range doc.Rules {
+		r := &doc.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if r.Status == 0 {
+			r.Status = http.StatusOK
+		}
+		if r.Status < 100 || r.Status > 599 {
+			return nil, validationError("%s: %s: invalid status %d", path, r.Name, r.Status)
+		}
+		if r.Times < 0 {
+			return nil, validationError("%s: %s: times must not be negative", path, r.Name)
+		}
+		if r.Delay != "" {
+			if r.delay, err = time.ParseDuration(r.Delay); err != nil || r.delay < 0 {
+				return nil, validationError("%s: %s: invalid delay %q (use a duration like 2s)", path, r.Name, r.Delay)
+			}
+		}
+		for field := range r.Fields {
+			if field == "" {
+				return nil, validationError("%s: %s: empty field path", path, r.Name)
+			}
+		}
+	}
+	return &mockResponder{Path: path, rules: doc.Rules, attempts: map[string]int{}}, nil
+}
+
+// respond returns the reply of the first matching rule, or nil if none
+// matches. Each call counts as an attempt of the delivered event.
+func (m *mockResponder) respond(h webhookHeaders, body []byte) *listenReply {
+	var payload interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&payload) != nil {
+		payload = nil
+	}
+
+	// Attempts are counted per event; deliveries without an ID are told
+	// apart by their body.
+	event := h.EventID
+	if event == "" {
+		sum := sha256.Sum256(body)
+		event = hex.EncodeToString(sum[:8])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.rules {
+		if !r.matches(h, payload) {
+			continue
+		}
+		key := strconv.Itoa(i) + "\x00" + event
+		attempt := m.attempts[key] + 1
+		if r.Times > 0 && attempt > r.Times {
+			continue
+		}
+		m.attempts[key] = attempt
+
+		reply := &listenReply{
+			Status: r.Status,
+			Header: http.Header{},
+			Body:   []byte(r.Body),
+			Delay:  r.delay,
+			Source: replyRule,
+			Rule:   r.Name,
+			Note:   fmt.Sprintf("attempt %d", attempt),
+		}
+		if r.Times > 0 {
+			reply.Note += fmt.Sprintf(" of %d", r.Times)
+		}
+		if json.Valid(reply.Body) {
+			reply.Header.Set("Content-Type", "application/json")
+		}
+		for name, value := range r.Headers {
+			reply.Header.Set(name, value)
+		}
+		return reply
+	}
+	return nil
+}
+
+func (r respondRule) matches(h webhookHeaders, payload interface{}) bool {
+	if r.Type != "" && !matchPattern(h.EventType, r.Type) {
+		return false
+	}
+	if r.EventID != "" && r.EventID != h.EventID {
+		return false
+	}
+	for path, want := range r.Fields {
+		got, ok := lookupField(payload, path)
+		if !ok || (want != "*" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupField finds a dotted path such as "data.items.0.sku" in a decoded
+// JSON payload and formats the value for comparison.
+func lookupField(v interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	}
+}
+
+// jsonReply is a reply from the listener itself.
+func jsonReply(status int, v interface{}) *listenReply {
+	body, _ := json.Marshal(v)
+	return &listenReply{
+		Status: status,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   body,
+		Source: replyListener,
+	}
+}
+
+// write sends the reply, after its delay.
+func (r *listenReply) write(w http.ResponseWriter) {
+	if r.Delay > 0 {
+		time.Sleep(r.Delay)
+	}
+	for name, values := range r.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.Status)
+	w.Write(r.Body)
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRespondFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "respond.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRespondRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: "rules:\n  - type: payment.*\n    status: 503\n    delay: 2s\n"},
+		{name: "defaults", content: "rules:\n  - event_id: evt_1\n"},
+		{name: "no rules", content: "rules: []\n", wantErr: "has no rules"},
+		{name: "unknown key", content: "rules:\n  - stauts: 500\n", wantErr: "field stauts not found"},
+		{name: "invalid status", content: "rules:\n  - status: 700\n", wantErr: "invalid status 700"},
+		{name: "negative times", content: "rules:\n  - times: -1\n", wantErr: "times must not be negative"},
+		{name: "invalid delay", content: "rules:\n  - delay: soon\n", wantErr: `invalid delay "soon"`},
+		{name: "negative delay", content: "rules:\n  - delay: -1s\n", wantErr: "invalid delay"},
+		{name: "empty field path", content: "rules:\n  - fields: {\"\": x}\n", wantErr: "empty field path"},
+		{name: "not yaml", content: "rules: [\n", wantErr: "parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := loadRespondRules(writeRespondFile(t, tt.content))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadRespondRules: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("loadRespondRules = %+v, want error containing %q", m, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := loadRespondRules(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("loadRespondRules of a missing file: want error")
+	}
+}
+
+func TestLoadRespondRulesDefaults(t *testing.T) {
+	m, err := loadRespondRules(writeRespondFile(t, "rules:\n  - type: a\n  - name: second\n    delay: 1500ms\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.rules[0]; got.Name != "rule 1" || got.Status != 200 {
+		t.Errorf("first rule = %q status %d, want \"rule 1\" status 200", got.Name, got.Status)
+	}
+	if got := m.rules[1]; got.Name != "second" || got.delay != 1500*time.Millisecond {
+		t.Errorf("second rule = %q delay %s, want \"second\" delay 1.5s", got.Name, got.delay)
+	}
+}
+
+func TestRespondRuleMatches(t *testing.T) {
+	payload := map[string]interface{}{
+		"id": "evt_1",
+		"data": map[string]interface{}{
+			"amount":   json.Number("2000"),
+			"currency": "usd",
+			"items":    []interface{}{map[string]interface{}{"sku": "sku_1"}},
+		},
+	}
+	h := webhookHeaders{EventID: "evt_1", EventType: "payment.failed"}
+
+	tests := []struct {
+		name string
+		rule respondRule
+		want bool
+	}{
+		{name: "no conditions", rule: respondRule{}, want: true},
+		{name: "exact type", rule: respondRule{Type: "payment.failed"}, want: true},
+		{name: "wildcard type", rule: respondRule{Type: "payment.*"}, want: true},
+		{name: "other type", rule: respondRule{Type: "refund.*"}, want: false},
+		{name: "event id", rule: respondRule{EventID: "evt_1"}, want: true},
+		{name: "other event id", rule: respondRule{EventID: "evt_2"}, want: false},
+		{name: "number field", rule: respondRule{Fields: map[string]string{"data.amount": "2000"}}, want: true},
+		{name: "nested array field", rule: respondRule{Fields: map[string]string{"data.items.0.sku": "sku_1"}}, want: true},
+		{name: "any value", rule: respondRule{Fields: map[string]string{"data.currency": "*"}}, want: true},
+		{name: "missing field", rule: respondRule{Fields: map[string]string{"data.refund": "*"}}, want: false},
+		{name: "all conditions", rule: respondRule{Type: "payment.*", Fields: map[string]string{"data.currency": "usd", "data.amount": "2000"}}, want: true},
+		{name: "one condition fails", rule: respondRule{Type: "payment.*", Fields: map[string]string{"data.currency": "eur"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(h, payload); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	var payload interface{}
+	dec := json.NewDecoder(strings.NewReader(`{
+		"data": {"amount": 2000, "ratio": 0.5, "paid": true, "note": null,
+		         "items": [{"sku": "a"}, {"sku": "b"}], "meta": {"k": "v"}}
+	}`))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "data.amount", want: "2000", wantOK: true},
+		{path: "data.ratio", want: "0.5", wantOK: true},
+		{path: "data.paid", want: "true", wantOK: true},
+		{path: "data.note", want: "null", wantOK: true},
+		{path: "data.items.1.sku", want: "b", wantOK: true},
+		{path: "data.meta", want: `{"k":"v"}`, wantOK: true},
+		{path: "data.items.2.sku", wantOK: false},
+		{path: "data.items.x", wantOK: false},
+		{path: "data.items.-1", wantOK: false},
+		{path: "data.amount.value", wantOK: false},
+		{path: "missing", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupField(payload, tt.path)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("lookupField(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMockResponderRespond(t *testing.T) {
+	m, err := loadRespondRules(writeRespondFile(t, `rules:
+  - name: flaky payments
+    type: payment.*
+    times: 2
+    status: 503
+    headers: {Retry-After: "10"}
+  - name: declined
+    fields: {data.amount: "2000"}
+    status: 402
+    body: '{"error": "card declined"}'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment := []byte(`{"id":"evt_1","data":{"amount":2000}}`)
+	other := []byte(`{"id":"evt_2","data":{"amount":100}}`)
+
+	// Each step delivers one event and checks the reply, in order.
+	steps := []struct {
+		name     string
+		headers  webhookHeaders
+		body     []byte
+		wantRule string // "" for no reply
+		status   int
+		note     string
+	}{
+		{name: "first attempt", headers: webhookHeaders{EventID: "evt_1", EventType: "payment.failed"}, body: payment, wantRule: "flaky payments", status: 503, note: "attempt 1 of 2"},
+		{name: "second attempt", headers: webhookHeaders{EventID: "evt_1", EventType: "payment.failed"}, body: payment, wantRule: "flaky payments", status: 503, note: "attempt 2 of 2"},
+		{name: "times used up", headers: webhookHeaders{EventID: "evt_1", EventType: "payment.failed"}, body: payment, wantRule: "declined", status: 402, note: "attempt 1"},
+		{name: "counted per event", headers: webhookHeaders{EventID: "evt_3", EventType: "payment.failed"}, body: payment, wantRule: "flaky payments", status: 503, note: "attempt 1 of 2"},
+		{name: "no rule matches", headers: webhookHeaders{EventID: "evt_2", EventType: "refund.created"}, body: other},
+		{name: "not JSON", headers: webhookHeaders{EventType: "refund.created"}, body: []byte("not json")},
+		{name: "counted by body without an ID", headers: webhookHeaders{EventType: "payment.failed"}, body: other, wantRule: "flaky payments", status: 503, note: "attempt 1 of 2"},
+		{name: "same body again", headers: webhookHeaders{EventType: "payment.failed"}, body: other, wantRule: "flaky payments", status: 503, note: "attempt 2 of 2"},
+	}
+	for _, s := range steps {
+		reply := m.respond(s.headers, s.body)
+		if s.wantRule == "" {
+			if reply != nil {
+				t.Errorf("%s: got reply from %q, want none", s.name, reply.Rule)
+			}
+			continue
+		}
+		if reply == nil {
+			t.Errorf("%s: got no reply, want one from %q", s.name, s.wantRule)
+			continue
+		}
+		if reply.Rule != s.wantRule || reply.Status != s.status || reply.Note != s.note || reply.Source != replyRule {
+			t.Errorf("%s: got %s/%q %d %q, want %s/%q %d %q", s.name,
+				reply.Source, reply.Rule, reply.Status, reply.Note, replyRule, s.wantRule, s.status, s.note)
+		}
+	}
+}
+
+func TestMockResponderReplyHeaders(t *testing.T) {
+	m, err := loadRespondRules(writeRespondFile(t, `rules:
+  - type: json
+    body: '{"ok": false}'
+    headers: {Retry-After: "10"}
+  - type: text
+    body: nope
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := m.respond(webhookHeaders{EventType: "json"}, nil)
+	if got := reply.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("JSON body Content-Type = %q, want application/json", got)
+	}
+	if got := reply.Header.Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	if !bytes.Equal(reply.Body, []byte(`{"ok": false}`)) {
+		t.Errorf("body = %q", reply.Body)
+	}
+
+	reply = m.respond(webhookHeaders{EventType: "text"}, nil)
+	if got := reply.Header.Get("Content-Type"); got != "" {
+		t.Errorf("text body Content-Type = %q, want none", got)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// With --stream, 'sapliy listen' needs no public URL: it connects out to the
+// event stream, turns each event into a signed delivery to the local
+// application, and reports the outcome back as a delivery attempt so it
+// shows up in 'sapliy webhooks inspect'.
+
+// streamAttempt is a delivery attempt made by the CLI, as reported to
+// POST /v1/events/{id}/attempts.
+type streamAttempt struct {
+	Source       string    `json:"source"`
+	Endpoint     string    `json:"endpoint"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+	StatusCode   int       `json:"status_code"`
+	Error        string    `json:"error,omitempty"`
+	LatencyMS    int64     `json:"latency_ms"`
+	ResponseBody string    `json:"response_body,omitempty"`
+}
+
+// streamListener delivers streamed events to the local application.
+type streamListener struct {
+	Zone      string
+	Pattern   string
+	Secrets   []string
+	Forwarder *deliveryForwarder
+	Recorder  *captureRecorder
+}
+
+// run streams events until ctx is cancelled.
+func (l *streamListener) run(ctx context.Context) error {
+	return runEventStream(ctx, l.Zone, func(message []byte) {
+		var event deliveryEvent
+		if err := json.Unmarshal(message, &event); err != nil || event.Type == "" {
+			return
+		}
+		if l.Pattern != "*" && !matchPattern(event.Type, l.Pattern) {
+			return
+		}
+		l.deliver(ctx, event)
+	})
+}
+
+// deliver signs event, forwards it and reports the outcome.
+func (l *streamListener) deliver(ctx context.Context, event deliveryEvent) {
+	yellow := color.New(color.FgYellow)
+
+	eventID := event.ID
+	if eventID == "" {
+		eventID = newEventID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	}
+	if len(event.Data) == 0 {
+		event.Data = json.RawMessage("{}")
+	}
+	body, err := json.Marshal(deliveryEvent{ID: eventID, Type: event.Type, Data: event.Data, CreatedAt: event.CreatedAt})
+	if err != nil {
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Sapliy-Event-ID", eventID)
+	header.Set("X-Sapliy-Event-Type", event.Type)
+	attemptedAt := time.Now()
+	signDelivery(header, l.Secrets, body, attemptedAt)
+
+	printDeliveryHeader(readWebhookHeaders(header))
+	res, fwdErr := l.Forwarder.forward(ctx, header, body)
+	printForwardOutcome(res, fwdErr)
+
+	if event.ID == "" {
+		yellow.Printf("  Not reported: the streamed event has no ID\n")
+	} else if err := l.report(ctx, event.ID, attemptedAt, res, fwdErr); err != nil && ctx.Err() == nil {
+		yellow.Printf("  Not reported to delivery history: %v\n", err)
+	}
+	c := newCapture("stream", http.MethodPost, "", header, body)
+	c.Signature = verdictSigned
+	c.setForward(l.Forwarder.URL, res, fwdErr)
+	l.Recorder.record(c)
+	printDeliveryPayload(body)
+}
+
+// report records a forwarding attempt in the event's delivery history.
+func (l *streamListener) report(ctx context.Context, eventID string, at time.Time, res *forwardResult, fwdErr error) error {
+	attempt := streamAttempt{
+		Source:      "cli",
+		Endpoint:    l.Forwarder.URL,
+		AttemptedAt: at.UTC(),
+	}
+	if fwdErr != nil {
+		attempt.Error = fwdErr.Error()
+	} else {
+		attempt.StatusCode = res.StatusCode
+		attempt.LatencyMS = res.Latency.Milliseconds()
+		attempt.ResponseBody = truncate(string(res.Body), 2000)
+	}
+
+	q := url.Values{}
+	q.Set("zone_id", l.Zone)
+	path := "/v1/events/" + url.PathEscape(eventID) + "/attempts?" + q.Encode()
+	return apiRequest(withIdempotencyKey(ctx, newIdempotencyKey()), http.MethodPost, path, attempt, nil)
+}
+
+// describe prints the listener's settings before it connects.
+func (l *streamListener) describe() {
+	color.New(color.FgGreen, color.Bold).Printf("\n🎧 Sapliy Webhook Listener (event stream)\n")
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+	fmt.Fprintf(stdout, "Streaming from: %s\n", eventStreamURL(l.Zone))
+	fmt.Fprintf(stdout, "Event filter:   %s\n", l.Pattern)
+	fmt.Fprintf(stdout, "Forwarding to:  %s\n", l.Forwarder.URL)
+	fmt.Fprintf(stdout, "Signing:        %d secret(s), %s\n", len(l.Secrets), signatureVersion)
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+}
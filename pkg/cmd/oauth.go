@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultAPIURL        = "http://localhost:8080"
+	defaultOAuthClientID = "sapliy-cli"
+
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// deviceAuthorization is the response of the device authorization endpoint
+// (RFC 8628, section 3.2).
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// oauthToken is a successful token endpoint response.
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+// errOAuthNotFound is returned when the server does not expose an OAuth endpoint.
+var errOAuthNotFound = errors.New("oauth endpoint not found")
+
+// oauthError is an error response from the token endpoint (RFC 6749, section 5.2).
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// authBaseURL is the server hosting the OAuth endpoints. It defaults to the
+// API URL so self-hosted stacks and local stand-in servers need no extra config.
+func authBaseURL() string {
+	if u := viper.GetString("auth_url"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return apiBaseURL()
+}
+
+func oauthClientID() string {
+	if id := viper.GetString("oauth_client_id"); id != "" {
+		return id
+	}
+	return defaultOAuthClientID
+}
+
+// requestDeviceCode starts the device authorization flow.
+func requestDeviceCode(ctx context.Context, hc *http.Client) (*deviceAuthorization, error) {
+	form := url.Values{
+		"client_id": {oauthClientID()},
+		"scope":     {"cli offline_access"},
+	}
+
+	var auth deviceAuthorization
+	if err := postOAuthForm(ctx, hc, "/oauth/device/code", form, &auth); err != nil {
+		return nil, fmt.Errorf("request device code: %w", err)
+	}
+	if auth.DeviceCode == "" || auth.UserCode == "" || auth.VerificationURI == "" {
+		return nil, errors.New("request device code: incomplete response from server")
+	}
+	if auth.Interval <= 0 {
+		auth.Interval = 5
+	}
+	if auth.ExpiresIn <= 0 {
+		auth.ExpiresIn = 600
+	}
+	return &auth, nil
+}
+
+// pollDeviceToken polls the token endpoint until the user approves or denies
+// the request, or the device code expires.
+func pollDeviceToken(ctx context.Context, hc *http.Client, auth *deviceAuthorization) (*oauthToken, error) {
+	interval := time.Duration(auth.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	form := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {auth.DeviceCode},
+		"client_id":   {oauthClientID()},
+	}
+
+	for {
+		if time.Now().After(deadline) {
+			return nil, authError("device code expired before login was approved")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var token oauthToken
+		err := postOAuthForm(ctx, hc, "/oauth/token", form, &token)
+		if err == nil {
+			if token.AccessToken == "" {
+				return nil, errors.New("token response did not include an access token")
+			}
+			return &token, nil
+		}
+
+		var oerr *oauthError
+		if !errors.As(err, &oerr) {
+			return nil, err
+		}
+		switch oerr.Code {
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			// RFC 8628, section 3.5: increase the interval by 5 seconds.
+			interval += 5 * time.Second
+		case "expired_token":
+			return nil, authError("device code expired before login was approved")
+		case "access_denied":
+			return nil, authError("login was denied in the browser")
+		default:
+			return nil, oerr
+		}
+	}
+}
+
+// revokeOAuthToken asks the server to invalidate a token (RFC 7009).
+func revokeOAuthToken(ctx context.Context, hc *http.Client, token, tokenTypeHint string) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+		"client_id":       {oauthClientID()},
+	}
+	return postOAuthForm(ctx, hc, "/oauth/revoke", form, nil)
+}
+
+func postOAuthForm(ctx context.Context, hc *http.Client, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authBaseURL()+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errOAuthNotFound
+	}
+	if resp.StatusCode >= 400 {
+		var oerr oauthError
+		if json.NewDecoder(resp.Body).Decode(&oerr) == nil && oerr.Code != "" {
+			return &oerr
+		}
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, path)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// credentialFromToken converts a token response into a stored credential.
+func credentialFromToken(token *oauthToken) *credential {
+	c := &credential{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+	}
+	if token.ExpiresIn > 0 {
+		c.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return c
+}
+
+// openBrowser makes a best-effort attempt to open url in the user's browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
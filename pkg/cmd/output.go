@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/jmespath/go-jmespath"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// Output formats selected with -o/--output. Table is the human-readable
+// default; the others print the full resource so the CLI can be scripted.
+const (
+	outputTable        = "table"
+	outputJSON         = "json"
+	outputYAML         = "yaml"
+	outputCSV          = "csv"
+	outputTemplate     = "go-template"
+	outputTemplateFile = "go-template-file"
+)
+
+// checkOutputFormat validates an --output value such as "json" or
+// "go-template={{.id}}".
+func checkOutputFormat(value string) error {
+	_, _, err := parseOutputFormat(value)
+	return err
+}
+
+func parseOutputFormat(value string) (format, tmpl string, err error) {
+	name, arg, hasArg := strings.Cut(value, "=")
+	switch name {
+	case "", outputTable:
+		return outputTable, "", nil
+	case outputJSON, outputYAML, outputCSV:
+		if hasArg {
+			return "", "", fmt.Errorf("output format %q takes no argument", name)
+		}
+		return name, "", nil
+	case outputTemplate:
+		if arg == "" {
+			return "", "", errors.New("go-template output needs a template, e.g. -o 'go-template={{.id}}'")
+		}
+		return outputTemplate, arg, nil
+	case outputTemplateFile:
+		if arg == "" {
+			return "", "", errors.New("go-template-file output needs a path, e.g. -o go-template-file=out.tmpl")
+		}
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", "", fmt.Errorf("read template: %w", err)
+		}
+		return outputTemplate, string(data), nil
+	}
+	return "", "", fmt.Errorf("unknown output format %q (use table, json, yaml, csv, go-template=... or go-template-file=...)", value)
+}
+
+// outputFormat returns the selected format, falling back to a table (with a
+// warning) when the setting is invalid.
+func outputFormat() (format, tmpl string) {
+	format, tmpl, err := parseOutputFormat(viper.GetString("output"))
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: %v; using table output\n", err)
+		return outputTable, ""
+	}
+	return format, tmpl
+}
+
+// queryExpr is the --query JMESPath expression.
+var queryExpr string
+
+// outputQuery returns the compiled --query expression, or nil when no query
+// was given.
+func outputQuery() (*jmespath.JMESPath, error) {
+	if queryExpr == "" {
+		return nil, nil
+	}
+	q, err := jmespath.Compile(queryExpr)
+	if err != nil {
+		return nil, validationError("invalid --query %q: %v", queryExpr, err)
+	}
+	return q, nil
+}
+
+// resultFormat is outputFormat for command results: a query's result has no
+// table view, so it is printed as JSON unless another format was chosen.
+func resultFormat() (format, tmpl string, query *jmespath.JMESPath, err error) {
+	format, tmpl = outputFormat()
+	query, err = outputQuery()
+	if err != nil || query == nil {
+		return format, tmpl, query, err
+	}
+	switch format {
+	case outputTable:
+		format = outputJSON
+	case outputCSV:
+		return "", "", nil, validationError("--query cannot be combined with csv output")
+	}
+	return format, tmpl, query, nil
+}
+
+// structuredOutput reports whether stdout carries machine-readable data.
+func structuredOutput() bool {
+	format, _, _, _ := resultFormat()
+	return format != outputTable
+}
+
+// progress is where status messages go: stdout for tables, stderr when
+// stdout carries structured data.
+func progress() io.Writer {
+	if structuredOutput() {
+		return stderr
+	}
+	return stdout
+}
+
+// column is one field of a list in table and CSV output.
+type column[T any] struct {
+	Header string
+	Value  func(T) string
+}
+
+// printList renders items in the selected format. table prints the human
+// view; when it is nil a plain table is built from columns.
+func printList[T any](items []T, columns []column[T], table func()) error {
+	format, tmpl, query, err := resultFormat()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case outputTable:
+		if table != nil {
+			table()
+			return nil
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		headers := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = c.Header
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, item := range items {
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				row[i] = c.Value(item)
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	case outputCSV:
+		w := csv.NewWriter(stdout)
+		headers := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = strings.ToLower(strings.ReplaceAll(c.Header, " ", "_"))
+		}
+		w.Write(headers)
+		for _, item := range items {
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				row[i] = c.Value(item)
+			}
+			w.Write(row)
+		}
+		w.Flush()
+		return w.Error()
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return printStructured(items, format, tmpl, query)
+}
+
+// listWriter renders a list page by page, so long listings are printed as
+// they arrive instead of being held in memory. go-template output and
+// --query need the whole list and are buffered until close.
+type listWriter[T any] struct {
+	columns []column[T]
+	header  func()
+	row     func(T)
+
+	format, tmpl string
+	query        *jmespath.JMESPath
+	buffered     []T
+	csv          *csv.Writer
+	count        int
+}
+
+// newListWriter starts a list in the selected format. In table output header
+// is printed before the first item and row for each item.
+func newListWriter[T any](columns []column[T], header func(), row func(T)) (*listWriter[T], error) {
+	format, tmpl, query, err := resultFormat()
+	if err != nil {
+		return nil, err
+	}
+	w := &listWriter[T]{columns: columns, header: header, row: row, format: format, tmpl: tmpl, query: query}
+	if format == outputCSV {
+		w.csv = csv.NewWriter(stdout)
+		headers := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = strings.ToLower(strings.ReplaceAll(c.Header, " ", "_"))
+		}
+		w.csv.Write(headers)
+	}
+	return w, nil
+}
+
+// write renders one page of items.
+func (w *listWriter[T]) write(items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	defer func() { w.count += len(items) }()
+
+	if w.query != nil || w.format == outputTemplate {
+		w.buffered = append(w.buffered, items...)
+		return nil
+	}
+
+	switch w.format {
+	case outputTable:
+		if w.count == 0 {
+			w.header()
+		}
+		for _, item := range items {
+			w.row(item)
+		}
+		return nil
+	case outputCSV:
+		for _, item := range items {
+			row := make([]string, len(w.columns))
+			for i, c := range w.columns {
+				row[i] = c.Value(item)
+			}
+			w.csv.Write(row)
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case outputJSON:
+		// Emit the elements of one JSON array, indented as printStructured
+		// would indent the whole list.
+		var buf strings.Builder
+		if w.count == 0 {
+			buf.WriteString("[\n")
+		}
+		for i, item := range items {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			var elem strings.Builder
+			enc := json.NewEncoder(&elem)
+			enc.SetIndent("  ", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(json.RawMessage(data)); err != nil {
+				return err
+			}
+			if w.count > 0 || i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString("  " + strings.TrimSuffix(elem.String(), "\n"))
+		}
+		_, err := io.WriteString(stdout, buf.String())
+		return err
+	case outputYAML:
+		// Consecutive YAML sequences concatenate into a single sequence.
+		return printStructured(items, outputYAML, "", nil)
+	}
+	return fmt.Errorf("unsupported output format %q", w.format)
+}
+
+// close finishes the list. empty is printed in table output when there were
+// no items.
+func (w *listWriter[T]) close(empty string) error {
+	if w.query != nil || w.format == outputTemplate {
+		if w.buffered == nil {
+			w.buffered = []T{}
+		}
+		return printStructured(w.buffered, w.format, w.tmpl, w.query)
+	}
+
+	switch w.format {
+	case outputTable:
+		if w.count == 0 && empty != "" {
+			fmt.Fprintln(stdout, empty)
+		}
+	case outputCSV:
+		w.csv.Flush()
+		return w.csv.Error()
+	case outputJSON:
+		if w.count == 0 {
+			_, err := io.WriteString(stdout, "[]\n")
+			return err
+		}
+		_, err := io.WriteString(stdout, "\n]\n")
+		return err
+	case outputYAML:
+		if w.count == 0 {
+			_, err := io.WriteString(stdout, "[]\n")
+			return err
+		}
+	}
+	return nil
+}
+
+// printObject renders a single resource in the selected format. table prints
+// the human view.
+func printObject(v interface{}, table func()) error {
+	return printObjectTo(stdout, v, table)
+}
+
+// printObjectTo is printObject writing structured output to w.
+func printObjectTo(w io.Writer, v interface{}, table func()) error {
+	format, tmpl, query, err := resultFormat()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case outputTable:
+		table()
+		return nil
+	case outputCSV:
+		return errors.New("csv output is only supported for lists")
+	}
+	return printStructuredTo(w, v, format, tmpl, query)
+}
+
+func printStructured(v interface{}, format, tmpl string, query *jmespath.JMESPath) error {
+	return printStructuredTo(stdout, v, format, tmpl, query)
+}
+
+// printStructuredTo is printStructured writing to w.
+func printStructuredTo(w io.Writer, v interface{}, format, tmpl string, query *jmespath.JMESPath) error {
+	// Round-trip through JSON so YAML, templates and queries see the same
+	// field names as JSON output.
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	if query != nil {
+		if generic, err = query.Search(generic); err != nil {
+			return validationError("--query: %v", err)
+		}
+		if data, err = json.Marshal(generic); err != nil {
+			return err
+		}
+	}
+
+	if format == outputJSON {
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(json.RawMessage(data)); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, buf.String())
+		return err
+	}
+
+	switch format {
+	case outputYAML:
+		out, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case outputTemplate:
+		t, err := template.New("output").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("parse template: %w", err)
+		}
+		var buf strings.Builder
+		if err := t.Execute(&buf, generic); err != nil {
+			return fmt.Errorf("execute template: %w", err)
+		}
+		_, err = io.WriteString(w, buf.String())
+		return err
+	}
+	return fmt.Errorf("unsupported output format %q", format)
+}
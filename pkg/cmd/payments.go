@@ -3,7 +3,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 
 	fintech "github.com/sapliy/fintech-sdk-go"
 	"github.com/spf13/cobra"
@@ -18,30 +17,35 @@ var paymentsCmd = &cobra.Command{
 var createPaymentCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a payment",
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: Not authenticated. Use 'sapliy auth login'.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
 		}
 
 		amount, _ := cmd.Flags().GetInt64("amount")
 		currency, _ := cmd.Flags().GetString("currency")
 
-		client := fintech.NewClient(apiKey)
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
 		zone := viper.GetString("current_zone")
-		payment, err := client.Payments.CreateIntent(context.Background(), &fintech.PaymentIntentRequest{
+		key := commandIdempotencyKey(cmd)
+		ctx := withIdempotencyKey(context.Background(), key)
+		payment, err := client.Payments.CreateIntent(ctx, &fintech.PaymentIntentRequest{
 			Amount:   amount,
 			Currency: currency,
 			ZoneID:   zone,
 		})
 
 		if err != nil {
-			fmt.Printf("Error creating payment: %v\n", err)
-			return
+			return withIdempotencyKeyHint(fmt.Errorf("create payment: %w", err), key)
 		}
 
-		fmt.Printf("Payment created successfully! ID: %s\n", payment.ID)
+		return printObject(payment, func() {
+			fmt.Fprintf(stdout, "Payment created successfully! ID: %s\n", payment.ID)
+		})
 	},
 }
 
@@ -50,5 +54,6 @@ func init() {
 	paymentsCmd.AddCommand(createPaymentCmd)
 	createPaymentCmd.Flags().Int64P("amount", "a", 0, "Amount in cents")
 	createPaymentCmd.Flags().StringP("currency", "c", "USD", "Currency code")
+	addIdempotencyKeyFlag(createPaymentCmd)
 	createPaymentCmd.MarkFlagRequired("amount")
 }
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultProfileName = "default"
+
+var profileNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// profileSummary is one row of 'sapliy profiles list'.
+type profileSummary struct {
+	Name        string `json:"name"`
+	Active      bool   `json:"active"`
+	APIURL      string `json:"api_url,omitempty"`
+	OrgID       string `json:"org_id,omitempty"`
+	CurrentZone string `json:"current_zone,omitempty"`
+}
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage named configuration profiles",
+	Long: `Profiles hold separate credentials, API URL, organization and current zone,
+so you can switch between staging, self-hosted and production without
+overwriting your config.
+
+The active profile is chosen by --profile, then SAPLIY_PROFILE, then the
+profile selected with 'sapliy profiles use'.`,
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		doc, _, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+
+		active := activeProfileName()
+		var profiles []profileSummary
+		for _, name := range profileNames(doc) {
+			profiles = append(profiles, profileSummary{
+				Name:        name,
+				Active:      name == active,
+				APIURL:      profileValue(doc, name, "api_url"),
+				OrgID:       profileValue(doc, name, "org_id"),
+				CurrentZone: profileValue(doc, name, "current_zone"),
+			})
+		}
+
+		return printList(profiles, []column[profileSummary]{
+			{"NAME", func(p profileSummary) string { return p.Name }},
+			{"ACTIVE", func(p profileSummary) string { return strconv.FormatBool(p.Active) }},
+			{"API URL", func(p profileSummary) string { return p.APIURL }},
+			{"ORG", func(p profileSummary) string { return p.OrgID }},
+			{"ZONE", func(p profileSummary) string { return p.CurrentZone }},
+		}, func() {
+			if len(profiles) == 0 {
+				fmt.Fprintln(stdout, "No profiles configured. Use 'sapliy profiles create <name>'.")
+				return
+			}
+
+			fmt.Fprintf(stdout, "  %-16s %-32s %-20s %-20s\n", "NAME", "API URL", "ORG", "ZONE")
+			for _, p := range profiles {
+				marker := " "
+				if p.Active {
+					marker = "*"
+				}
+				fmt.Fprintf(stdout, "%s %-16s %-32s %-20s %-20s\n", marker, p.Name, p.APIURL, p.OrgID, p.CurrentZone)
+			}
+		})
+	},
+}
+
+var profilesCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a new profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+		if !profileNameRe.MatchString(name) {
+			return validationError("invalid profile name %q (use letters, digits, '-' and '_')", args[0])
+		}
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		if _, exists := doc.get("profiles." + name); exists {
+			return newCLIError(kindConflict, "profile %q already exists", name)
+		}
+
+		profile := map[string]interface{}{}
+		for flag, key := range map[string]string{
+			"api-url": "api_url",
+			"org-id":  "org_id",
+			"zone":    "current_zone",
+		} {
+			if value, _ := cmd.Flags().GetString(flag); value != "" {
+				profile[key] = value
+			}
+		}
+		doc.set("profiles."+name, profile)
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			if err := storeProfileCredential(doc, name, &credential{APIKey: apiKey}); err != nil {
+				return fmt.Errorf("save credentials: %w", err)
+			}
+		}
+
+		use, _ := cmd.Flags().GetBool("use")
+		if use {
+			doc.set("active_profile", name)
+		}
+
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "Created profile: %s\n", name)
+		if use {
+			fmt.Fprintf(stdout, "Switched to profile: %s\n", name)
+		}
+		return nil
+	},
+}
+
+var profilesUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Set the active profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		if _, exists := doc.get("profiles." + name); !exists {
+			return profileNotFoundError(name)
+		}
+
+		doc.set("active_profile", name)
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "Switched to profile: %s\n", name)
+		if env := os.Getenv("SAPLIY_PROFILE"); env != "" && env != name {
+			fmt.Fprintf(stdout, "Note: SAPLIY_PROFILE=%s still takes precedence in this shell.\n", env)
+		}
+		return nil
+	},
+}
+
+var profilesDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+
+		doc, path, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		credID := profileValue(doc, name, "credential")
+		if !doc.unset("profiles." + name) {
+			return profileNotFoundError(name)
+		}
+
+		if credID != "" {
+			store, err := openCredentialStore()
+			if err == nil && store.remove(credID) {
+				err = store.save()
+			}
+			if err != nil {
+				return fmt.Errorf("remove credentials: %w", err)
+			}
+		}
+		if active, _ := doc.get("active_profile"); active == name {
+			doc.unset("active_profile")
+		}
+
+		if err := doc.save(path); err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "Deleted profile: %s\n", name)
+		return nil
+	},
+}
+
+var profilesShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show the settings of a profile (default: active profile)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := activeProfileName()
+		if len(args) > 0 {
+			name = strings.ToLower(args[0])
+		}
+
+		doc, _, err := loadConfigDoc()
+		if err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+		if _, exists := doc.get("profiles." + name); !exists {
+			return profileNotFoundError(name)
+		}
+
+		settings := map[string]string{}
+		for _, key := range profileKeys {
+			value := profileValue(doc, name, key)
+			if key == "api_key" && value != "" {
+				value = "********"
+			}
+			if value != "" {
+				settings[key] = value
+			}
+		}
+		profile := struct {
+			Name     string            `json:"name"`
+			Active   bool              `json:"active"`
+			Settings map[string]string `json:"settings"`
+		}{name, name == activeProfileName(), settings}
+
+		return printObject(profile, func() {
+			fmt.Fprintf(stdout, "Profile: %s", name)
+			if profile.Active {
+				fmt.Fprint(stdout, " (active)")
+			}
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout, strings.Repeat("─", 40))
+			for _, key := range profileKeys {
+				fmt.Fprintf(stdout, "%-14s %s\n", key+":", valueOrDash(settings[key]))
+			}
+		})
+	},
+}
+
+// activeProfileName resolves the profile in effect for this invocation:
+// --profile, then SAPLIY_PROFILE, then active_profile from the config file.
+func activeProfileName() string {
+	if name := viper.GetString("profile"); name != "" {
+		return strings.ToLower(name)
+	}
+	if name := viper.GetString("active_profile"); name != "" {
+		return strings.ToLower(name)
+	}
+	return defaultProfileName
+}
+
+// applyProfile overlays the active profile's settings onto viper so that the
+// rest of the CLI can keep reading plain keys such as "api_key".
+func applyProfile() {
+	name := activeProfileName()
+	if !viper.IsSet("profiles." + name) {
+		if viper.IsSet("profile") {
+			fmt.Fprintf(stderr, "Warning: profile %q does not exist; using top-level settings\n", name)
+		}
+		return
+	}
+
+	settings := viper.GetStringMapString("profiles." + name)
+	for _, key := range profileKeys {
+		value := settings[key]
+		if value == "" {
+			continue
+		}
+		k, _ := lookupConfigKey(key)
+		if k.Env != "" {
+			if _, fromEnv := os.LookupEnv(k.Env); fromEnv {
+				continue
+			}
+		}
+		if k.Flag != "" {
+			if f := rootCmd.PersistentFlags().Lookup(k.Flag); f != nil && f.Changed {
+				continue
+			}
+		}
+		viper.Set(key, value)
+	}
+}
+
+// saveProfileValue persists key into the active profile and updates the
+// in-memory config to match.
+func saveProfileValue(key, value string) error {
+	doc, path, err := loadConfigDoc()
+	if err != nil {
+		return err
+	}
+
+	doc.set(fmt.Sprintf("profiles.%s.%s", activeProfileName(), key), value)
+	if err := doc.save(path); err != nil {
+		return err
+	}
+
+	viper.Set(key, value)
+	return nil
+}
+
+func profileNames(doc configDoc) []string {
+	raw, _ := doc.get("profiles")
+	profiles, _ := asStringMap(raw)
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func profileNotFoundError(name string) error {
+	e := newCLIError(kindNotFound, "profile %q not found", name)
+	e.Hint = "run 'sapliy profiles list' to see configured profiles"
+	return e
+}
+
+func profileValue(doc configDoc, profile, key string) string {
+	value, ok := doc.get(fmt.Sprintf("profiles.%s.%s", profile, key))
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	profilesCmd.AddCommand(profilesListCmd)
+	profilesCmd.AddCommand(profilesCreateCmd)
+	profilesCmd.AddCommand(profilesUseCmd)
+	profilesCmd.AddCommand(profilesDeleteCmd)
+	profilesCmd.AddCommand(profilesShowCmd)
+
+	profilesCreateCmd.Flags().String("api-key", "", "API key for the profile")
+	profilesCreateCmd.Flags().String("api-url", "", "API URL for the profile")
+	profilesCreateCmd.Flags().String("org-id", "", "Organization ID for the profile")
+	profilesCreateCmd.Flags().String("zone", "", "Current zone for the profile")
+	profilesCreateCmd.Flags().Bool("use", false, "Make the new profile active")
+}
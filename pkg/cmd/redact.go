@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// All CLI output goes through stdout and stderr, which mask secrets before
+// anything reaches the terminal, a log or a pipe: API keys, access and
+// refresh tokens, webhook secrets and signatures. --show-secrets turns the
+// masking off for local debugging.
+var (
+	stdout io.Writer = &redactingWriter{w: color.Output}
+	stderr io.Writer = &redactingWriter{w: color.Error}
+
+	// unmaskedStdout bypasses the masking, for the one output whose purpose
+	// is to reveal a newly issued secret.
+	unmaskedStdout io.Writer = color.Output
+)
+
+var showSecrets bool
+
+var (
+	secretsMu    sync.RWMutex
+	knownSecrets []string
+)
+
+// secretPatterns match secret material that was never registered, e.g. keys
+// echoed back by the server or signatures in webhook headers.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl func(match []string) string
+}{
+	// Query strings and form bodies: api_key=..., access_token=...
+	{
+		re: regexp.MustCompile(`(?i)\b(api_key|apikey|access_token|refresh_token|client_secret|token|secret|signature)=([^&\s"'*][^&\s"']*)`),
+		repl: func(m []string) string {
+			return m[1] + "=" + maskSecret(m[2])
+		},
+	},
+	// JSON and YAML fields: "api_key": "...", secret: ...
+	{
+		re: regexp.MustCompile(`(?i)("?(?:api_key|apikey|access_token|refresh_token|client_secret|webhook_secret|secret|signing_secret|password)"?\s*[:=]\s*["']?)([^"'\s,}*][^"'\s,}]*)`),
+		repl: func(m []string) string {
+			return m[1] + maskSecret(m[2])
+		},
+	},
+	// Authorization headers. The scheme must stand on its own so names like
+	// "fintech-basic" are left alone.
+	{
+		re: regexp.MustCompile(`(^|[\s:"'=])(Bearer|Basic|bearer)\s+([A-Za-z0-9._~+/=-]{8,})`),
+		repl: func(m []string) string {
+			return m[1] + m[2] + " " + maskSecret(m[3])
+		},
+	},
+	// Sapliy-style keys and secrets: sk_live_..., whsec_...
+	{
+		re: regexp.MustCompile(`\b((?:sk|rk|whsec)_(?:(?:test|live)_)?)([A-Za-z0-9]{8,})`),
+		repl: func(m []string) string {
+			return m[1] + maskSecret(m[2])
+		},
+	},
+	// Versioned signature headers: t=...,v1=<hex>
+	{
+		re: regexp.MustCompile(`\b(v\d+=)([0-9a-fA-F]{16,})`),
+		repl: func(m []string) string {
+			return m[1] + maskSecret(m[2])
+		},
+	},
+}
+
+// registerSecret marks a value that must never be printed verbatim.
+func registerSecret(secret string) {
+	if len(secret) < 6 {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range knownSecrets {
+		if s == secret {
+			return
+		}
+	}
+	knownSecrets = append(knownSecrets, secret)
+	// Replace longer secrets first so a secret containing another is masked whole.
+	sort.Slice(knownSecrets, func(i, j int) bool { return len(knownSecrets[i]) > len(knownSecrets[j]) })
+}
+
+// maskSecret keeps just enough of a secret to tell values apart.
+func maskSecret(secret string) string {
+	if showSecrets {
+		return secret
+	}
+	if len(secret) < 12 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// redact masks registered secrets and anything that looks like secret material.
+func redact(s string) string {
+	if showSecrets || s == "" {
+		return s
+	}
+
+	secretsMu.RLock()
+	for _, secret := range knownSecrets {
+		s = strings.ReplaceAll(s, secret, maskSecret(secret))
+	}
+	secretsMu.RUnlock()
+
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllStringFunc(s, func(match string) string {
+			return p.repl(p.re.FindStringSubmatch(match))
+		})
+	}
+	return s
+}
+
+// redactingWriter masks secrets in everything written through it. Each Write
+// is redacted on its own, which matches how fmt and the color printers emit
+// one formatted message per call.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func init() {
+	color.Output = stdout
+	color.Error = stderr
+	log.SetOutput(stderr)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+
+	rootCmd.PersistentFlags().BoolVar(&showSecrets, "show-secrets", false, "print API keys, tokens and signatures unmasked (local debugging only)")
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"context"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Transient failures (429, 5xx and transient network errors) are retried with jittered
+// exponential backoff. Mutating requests always carry an Idempotency-Key that
+// stays the same across retries, so a retry can never create a duplicate.
+
+const (
+	retryBaseDelay    = 500 * time.Millisecond
+	retryMaxDelay     = 10 * time.Second
+	retryMaxAfter     = time.Minute
+	defaultMaxRetries = 3
+)
+
+type idempotencyKeyContextKey struct{}
+
+// withIdempotencyKey makes mutating requests made with ctx use key.
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// newIdempotencyKey returns a random UUIDv4.
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// addIdempotencyKeyFlag registers --idempotency-key on a mutating command.
+func addIdempotencyKeyFlag(cmd *cobra.Command) {
+	cmd.Flags().String("idempotency-key", "", "Idempotency-Key to send (default: generated); reuse it to safely retry a failed run")
+}
+
+// commandIdempotencyKey returns the --idempotency-key value or a fresh key.
+func commandIdempotencyKey(cmd *cobra.Command) string {
+	if key, _ := cmd.Flags().GetString("idempotency-key"); key != "" {
+		return key
+	}
+	return newIdempotencyKey()
+}
+
+// setIdempotencyKey stamps mutating requests with the key from their context,
+// or a generated one.
+func setIdempotencyKey(req *http.Request) {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return
+	}
+	if req.Header.Get("Idempotency-Key") != "" {
+		return
+	}
+	key, _ := req.Context().Value(idempotencyKeyContextKey{}).(string)
+	if key == "" {
+		key = newIdempotencyKey()
+	}
+	req.Header.Set("Idempotency-Key", key)
+}
+
+// retryTransport retries transient failures. timeout bounds each attempt
+// rather than the whole sequence, so backoff does not eat into it.
+type retryTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retries := maxRetries()
+	// A body that cannot be rewound can only be sent once.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := t.send(r)
+		if attempt >= retries || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := retryDelay(attempt, resp)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if viper.GetBool("verbose") {
+			fmt.Fprintf(stderr, "Retrying %s %s in %s (attempt %d/%d): %s\n",
+				req.Method, req.URL.Path, wait.Round(time.Millisecond), attempt+1, retries, reason)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *retryTransport) send(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// shouldRetry reports whether a response or error is worth another attempt.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return retryableError(err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusNotImplemented:
+		return false
+	case resp.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether a transport error may go away on its own:
+// timeouts, refused or dropped connections and temporary DNS failures.
+// Certificate problems, unknown hosts and malformed requests fail the same
+// way every time, so they are reported at once.
+func retryableError(err error) bool {
+	var (
+		certErr     *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostErr     x509.HostnameError
+		invalidCert x509.CertificateInvalidError
+		dnsErr      *net.DNSError
+		netErr      net.Error
+	)
+	switch {
+	case errors.As(err, &certErr), errors.As(err, &unknownAuth),
+		errors.As(err, &hostErr), errors.As(err, &invalidCert):
+		return false
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.EOF):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// retryDelay honors Retry-After when the server sends it and otherwise uses
+// exponential backoff with jitter.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, retryMaxAfter)
+		}
+	}
+
+	d := min(retryBaseDelay<<min(attempt, 10), retryMaxDelay)
+	// Equal jitter: at least half the backoff, so retries still spread out.
+	return d/2 + rand.N(d/2+1)
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func maxRetries() int {
+	if !viper.IsSet("max_retries") {
+		return defaultMaxRetries
+	}
+	return max(viper.GetInt("max_retries"), 0)
+}
+
+// cancelOnClose releases a per-attempt context once the body is consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
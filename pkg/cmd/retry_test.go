@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "ok", status: http.StatusOK, want: false},
+		{name: "bad request", status: http.StatusBadRequest, want: false},
+		{name: "conflict", status: http.StatusConflict, want: false},
+		{name: "rate limited", status: http.StatusTooManyRequests, want: true},
+		{name: "internal error", status: http.StatusInternalServerError, want: true},
+		{name: "not implemented", status: http.StatusNotImplemented, want: false},
+		{name: "bad gateway", status: http.StatusBadGateway, want: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, want: true},
+		{name: "transient error", err: syscall.ECONNREFUSED, want: true},
+		{name: "permanent error", err: errors.New("unsupported protocol scheme"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := shouldRetry(resp, tt.err); got != tt.want {
+				t.Errorf("shouldRetry(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryableError(t *testing.T) {
+	opErr := func(err error) error {
+		return &url.Error{Op: "Post", URL: "https://api.sapliy.io/v1/events",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: err}}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: opErr(syscall.ECONNREFUSED), want: true},
+		{name: "connection reset", err: opErr(syscall.ECONNRESET), want: true},
+		{name: "unexpected EOF", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: true},
+		{name: "network timeout", err: &url.Error{Op: "Get", URL: "x", Err: timeoutError{}}, want: true},
+		{name: "temporary DNS failure", err: &net.DNSError{Err: "server misbehaving", Name: "api.sapliy.io", IsTemporary: true}, want: true},
+		{name: "unknown host", err: &net.DNSError{Err: "no such host", Name: "api.sapliy.io", IsNotFound: true}, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "unknown authority", err: &url.Error{Op: "Get", URL: "x", Err: x509.UnknownAuthorityError{}}, want: false},
+		{name: "certificate verification", err: &tls.CertificateVerificationError{Err: errors.New("expired")}, want: false},
+		{name: "hostname mismatch", err: x509.HostnameError{Host: "api.sapliy.io", Certificate: &x509.Certificate{}}, want: false},
+		{name: "other", err: errors.New("net/http: invalid header field"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryableError(tt.err); got != tt.want {
+				t.Errorf("retryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "0", want: 0, wantOK: true},
+		{value: "120", want: 2 * time.Minute, wantOK: true},
+		{value: "-1", wantOK: false},
+		{value: "soon", wantOK: false},
+		{value: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0, wantOK: true}, // in the past
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	future := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	got, ok := parseRetryAfter(future)
+	if !ok || got <= 20*time.Second || got > 30*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %s, %v; want about 30s", future, got, ok)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withRetryAfter := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+	tests := []struct {
+		name     string
+		attempt  int
+		resp     *http.Response
+		min, max time.Duration
+	}{
+		{name: "first attempt", attempt: 0, min: retryBaseDelay / 2, max: retryBaseDelay},
+		{name: "second attempt", attempt: 1, min: retryBaseDelay, max: 2 * retryBaseDelay},
+		{name: "capped", attempt: 30, min: retryMaxDelay / 2, max: retryMaxDelay},
+		{name: "no Retry-After", attempt: 0, resp: &http.Response{Header: http.Header{}}, min: retryBaseDelay / 2, max: retryBaseDelay},
+		{name: "Retry-After", attempt: 0, resp: withRetryAfter("3"), min: 3 * time.Second, max: 3 * time.Second},
+		{name: "Retry-After capped", attempt: 0, resp: withRetryAfter("3600"), min: retryMaxAfter, max: retryMaxAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				if got := retryDelay(tt.attempt, tt.resp); got < tt.min || got > tt.max {
+					t.Fatalf("retryDelay(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestSetIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		ctxKey string
+		header string
+		want   string // "" for none, "*" for a generated key
+	}{
+		{name: "get", method: http.MethodGet, want: ""},
+		{name: "post", method: http.MethodPost, want: "*"},
+		{name: "delete", method: http.MethodDelete, want: "*"},
+		{name: "from context", method: http.MethodPost, ctxKey: "key-1", want: "key-1"},
+		{name: "already set", method: http.MethodPatch, ctxKey: "key-1", header: "key-2", want: "key-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctxKey != "" {
+				ctx = withIdempotencyKey(ctx, tt.ctxKey)
+			}
+			req, _ := http.NewRequestWithContext(ctx, tt.method, "https://api.sapliy.io/v1/events", nil)
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			setIdempotencyKey(req)
+			got := req.Header.Get("Idempotency-Key")
+			switch tt.want {
+			case "*":
+				if len(got) != 36 {
+					t.Errorf("Idempotency-Key = %q, want a generated UUID", got)
+				}
+			default:
+				if got != tt.want {
+					t.Errorf("Idempotency-Key = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransportKeepsIdempotencyKey(t *testing.T) {
+	var keys, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(b))
+		if len(keys) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"type":"test"}`))
+	setIdempotencyKey(req)
+	rt := &retryTransport{base: http.DefaultTransport, timeout: 5 * time.Second}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(keys))
+	}
+	for i := range keys {
+		if keys[i] != keys[0] || keys[i] == "" {
+			t.Errorf("attempt %d Idempotency-Key = %q, want %q", i+1, keys[i], keys[0])
+		}
+		if bodies[i] != `{"type":"test"}` {
+			t.Errorf("attempt %d body = %q, want the original body", i+1, bodies[i])
+		}
+	}
+}
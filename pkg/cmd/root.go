@@ -17,23 +17,62 @@ var rootCmd = &cobra.Command{
 	Short:   "Sapliy Fintech Ecosystem CLI",
 	Long: `Sapliy CLI is the official command line interface for the Sapliy Fintech Ecosystem.
 It allows you to manage automation zones, flows, and interact with the event bus.`,
+	// Errors are reported by Execute, which also picks the exit code.
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Cobra checks required flags and flag groups after this hook; check
+		// them here so a bad combination is reported as a usage error.
+		if err := cmd.ValidateRequiredFlags(); err != nil {
+			return err
+		}
+		if err := cmd.ValidateFlagGroups(); err != nil {
+			return err
+		}
+		if _, err := outputQuery(); err != nil {
+			return err
+		}
+		commandStarted = true
+		return nil
+	},
 }
 
+// commandStarted is set once arguments and flags were accepted, so errors
+// raised before it are usage errors.
+var commandStarted bool
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return
 	}
+	if !commandStarted {
+		e := classifyError(err)
+		e.Kind = kindValidation
+		e.Hint = fmt.Sprintf("run '%s --help' for usage", cmd.CommandPath())
+		err = e
+	}
+	os.Exit(printError(err))
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.sapliy.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "config profile to use (default is the active profile)")
+	rootCmd.PersistentFlags().String("api-key", "", "API key to use instead of the stored credential")
 	rootCmd.PersistentFlags().Bool("verbose", false, "enable verbose output")
+	rootCmd.PersistentFlags().StringP("output", "o", outputTable, "output format: table, json, yaml, csv, go-template=TEMPLATE or go-template-file=PATH")
+	rootCmd.PersistentFlags().StringVar(&queryExpr, "query", "", "JMESPath expression applied to the result before it is printed, e.g. \"[?mode=='live'].id\"")
+	rootCmd.PersistentFlags().Duration("timeout", defaultRequestTimeout, "timeout for each API request")
+	rootCmd.PersistentFlags().Bool("insecure-skip-verify", false, "skip TLS certificate verification (local development only)")
 
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("insecure_skip_verify", rootCmd.PersistentFlags().Lookup("insecure-skip-verify"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -43,8 +82,7 @@ func initConfig() {
 	} else {
 		home, err := os.UserHomeDir()
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			os.Exit(printError(err))
 		}
 
 		viper.AddConfigPath(home)
@@ -54,21 +92,25 @@ func initConfig() {
 	viper.SetEnvPrefix("SAPLIY")
 	viper.AutomaticEnv()
 
-	// Explicitly bind environment variables
-	viper.BindEnv("api_key", "SAPLIY_API_KEY")
-	viper.BindEnv("api_url", "SAPLIY_API_URL")
-	viper.BindEnv("org_id", "SAPLIY_ORG_ID")
-	viper.BindEnv("current_zone", "SAPLIY_ZONE")
+	// Explicitly bind environment variables and defaults
+	bindConfigSchema()
 
 	if err := viper.ReadInConfig(); err == nil {
 		if viper.GetBool("verbose") {
-			fmt.Println("Using config file:", viper.ConfigFileUsed())
+			fmt.Fprintln(progress(), "Using config file:", viper.ConfigFileUsed())
 		}
 	}
 
+	applyProfile()
+	registerSecret(viper.GetString("api_key"))
+	for _, secret := range webhookSecrets() {
+		registerSecret(secret)
+	}
+
 	if viper.GetBool("verbose") {
-		fmt.Printf("DEBUG: api_key='%s', api_url='%s', org_id='%s'\n",
-			viper.GetString("api_key"),
+		fmt.Fprintf(progress(), "DEBUG: profile='%s', api_key='%s', api_url='%s', org_id='%s'\n",
+			activeProfileName(),
+			maskSecret(viper.GetString("api_key")),
 			viper.GetString("api_url"),
 			viper.GetString("org_id"))
 	}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -90,24 +89,24 @@ var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run the Sapliy Automation Studio locally",
 	Long:  `Hosts the self-contained Sapliy Automation Studio web interface locally and proxies API requests.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		port, _ := cmd.Flags().GetString("port")
 		apiURL, _ := cmd.Flags().GetString("api")
 
-		fmt.Printf("🚀 Sapliy Automation Studio starting...\n")
-		fmt.Printf("   ├── UI: http://localhost:%s\n", port)
-		fmt.Printf("   └── API Proxy: %s\n", apiURL)
+		fmt.Fprintf(stdout, "🚀 Sapliy Automation Studio starting...\n")
+		fmt.Fprintf(stdout, "   ├── UI: http://localhost:%s\n", port)
+		fmt.Fprintf(stdout, "   └── API Proxy: %s\n", apiURL)
 
 		// Prepare FS
 		fsys, err := fs.Sub(content, "ui")
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		// API Proxy Handler
 		target, err := url.Parse(apiURL)
 		if err != nil {
-			log.Fatal(err)
+			return validationError("invalid API URL %q: %v", apiURL, err)
 		}
 		proxy := httputil.NewSingleHostReverseProxy(target)
 
@@ -127,9 +126,7 @@ var runCmd = &cobra.Command{
 		// Handle UI
 		mux.Handle("/", &SPAHandler{staticFS: fsys})
 
-		if err := http.ListenAndServe(":"+port, mux); err != nil {
-			log.Fatal(err)
-		}
+		return http.ListenAndServe(":"+port, mux)
 	},
 }
 
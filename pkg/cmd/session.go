@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Commands never send the long-lived API key or refresh token with ordinary
+// API calls. Instead they exchange it at the token endpoint for a short-lived
+// access token, cache that token with its expiry (in the credential store
+// when the credential lives there), and refresh it transparently.
+
+// tokenRefreshMargin refreshes access tokens slightly before they expire.
+const tokenRefreshMargin = time.Minute
+
+// tokenSource hands out access tokens for the credential of this invocation.
+type tokenSource struct {
+	mu sync.Mutex
+
+	apiKey string
+	cred   *credential
+
+	token     string
+	expiresAt time.Time
+
+	// passthrough is set when the server has no token exchange; the API key
+	// is then sent as-is.
+	passthrough bool
+}
+
+var (
+	sessionOnce   sync.Once
+	sessionTokens *tokenSource
+	sessionErr    error
+)
+
+// activeTokenSource returns the process-wide token source for the active
+// credential.
+func activeTokenSource() (*tokenSource, error) {
+	sessionOnce.Do(func() {
+		sessionTokens, sessionErr = newTokenSource()
+	})
+	return sessionTokens, sessionErr
+}
+
+func newTokenSource() (*tokenSource, error) {
+	secret, _, err := resolveCredential()
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, authError("not authenticated")
+	}
+
+	ts := &tokenSource{apiKey: secret}
+
+	// Stored credentials carry refresh tokens and cached access tokens.
+	if id := storedCredentialID(); id != "" {
+		c, err := storedCredential(id)
+		if err != nil {
+			return nil, err
+		}
+		ts.cred = c
+		ts.apiKey = c.APIKey
+		ts.token, ts.expiresAt = c.AccessToken, c.ExpiresAt
+	}
+	return ts, nil
+}
+
+// Token returns a valid access token, refreshing it when it is about to
+// expire or when force is set. bearer is false when the returned value is a
+// raw API key that must be sent in the X-API-Key header.
+func (ts *tokenSource) Token(ctx context.Context, force bool) (token string, bearer bool, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.passthrough {
+		return ts.apiKey, false, nil
+	}
+
+	fresh := ts.token != "" && (ts.expiresAt.IsZero() || time.Until(ts.expiresAt) > tokenRefreshMargin)
+	if fresh && !force {
+		return ts.token, true, nil
+	}
+
+	if err := ts.refresh(ctx); err != nil {
+		return "", false, err
+	}
+	if ts.passthrough {
+		return ts.apiKey, false, nil
+	}
+	return ts.token, true, nil
+}
+
+func (ts *tokenSource) refresh(ctx context.Context) error {
+	hc, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	var token *oauthToken
+	switch {
+	case ts.apiKey != "":
+		token, err = exchangeAPIKey(ctx, hc, ts.apiKey)
+		if errors.Is(err, errTokenExchangeUnsupported) {
+			ts.passthrough = true
+			return nil
+		}
+	case ts.cred != nil && ts.cred.RefreshToken != "":
+		token, err = refreshOAuthToken(ctx, hc, ts.cred.RefreshToken)
+	default:
+		return authError("session expired")
+	}
+	if err != nil {
+		return err
+	}
+
+	registerSecret(token.AccessToken)
+	registerSecret(token.RefreshToken)
+	ts.token = token.AccessToken
+	ts.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		ts.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	if ts.cred != nil {
+		ts.cred.AccessToken = ts.token
+		ts.cred.ExpiresAt = ts.expiresAt
+		// An API key is simply exchanged again, so only a browser login
+		// keeps a refresh token.
+		if token.RefreshToken != "" && ts.cred.APIKey == "" {
+			ts.cred.RefreshToken = token.RefreshToken
+		}
+		store, err := openCredentialStore()
+		if err != nil {
+			return err
+		}
+		return store.save()
+	}
+	return nil
+}
+
+var errTokenExchangeUnsupported = errors.New("token exchange not supported by server")
+
+// exchangeAPIKey trades an API key for a short-lived access token using the
+// client credentials grant.
+func exchangeAPIKey(ctx context.Context, hc *http.Client, apiKey string) (*oauthToken, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {oauthClientID()},
+		"client_secret": {apiKey},
+	}
+
+	var token oauthToken
+	err := postOAuthForm(ctx, hc, "/oauth/token", form, &token)
+
+	var oerr *oauthError
+	if errors.Is(err, errOAuthNotFound) || (errors.As(err, &oerr) && oerr.Code == "unsupported_grant_type") {
+		return nil, errTokenExchangeUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// refreshOAuthToken exchanges a refresh token for a new access token.
+func refreshOAuthToken(ctx context.Context, hc *http.Client, refreshToken string) (*oauthToken, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {oauthClientID()},
+	}
+
+	var token oauthToken
+	if err := postOAuthForm(ctx, hc, "/oauth/token", form, &token); err != nil {
+		return nil, fmt.Errorf("refresh access token: %w", err)
+	}
+	return &token, nil
+}
+
+// sessionTransport authenticates requests with the active access token and,
+// on a 401, refreshes the token and retries the request once.
+type sessionTransport struct {
+	base http.RoundTripper
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ts, err := activeTokenSource()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fix the key before any retry so every attempt carries the same one.
+	req = req.Clone(req.Context())
+	setIdempotencyKey(req)
+
+	resp, bearer, err := t.send(req, ts, false)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !bearer {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	resp, _, err = t.send(retry, ts, true)
+	return resp, err
+}
+
+func (t *sessionTransport) send(req *http.Request, ts *tokenSource, force bool) (*http.Response, bool, error) {
+	token, bearer, err := ts.Token(req.Context(), force)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r := req.Clone(req.Context())
+	if bearer {
+		r.Header.Del("X-API-Key")
+		r.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		r.Header.Set("X-API-Key", token)
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	return resp, bearer, err
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Webhook deliveries carry X-Sapliy-Signature: t=<unix seconds>,v1=<hex>.
+// Each v1 value is a hex-encoded HMAC-SHA256, keyed with a signing secret,
+// of the timestamp, a dot and the raw body, so a captured delivery cannot be
+// resent under a fresh timestamp. While a secret is being rotated the header
+// carries one v1 value per active secret. The timestamp is repeated in
+// X-Sapliy-Timestamp.
+//
+// Legacy senders use a bare hex HMAC of the body alone, usually in the
+// X-Webhook-* headers. It does not cover the timestamp, so it is only
+// accepted in compatibility mode.
+
+const (
+	defaultSignatureTolerance = 5 * time.Minute
+	signatureVersion          = "v1"
+)
+
+var (
+	errNoSignature      = errors.New("no signature header")
+	errSignatureInvalid = errors.New("signature does not match any signing secret")
+	errLegacySignature  = errors.New("legacy signature does not cover the timestamp " +
+		"(pass --allow-legacy-signatures or set webhook_legacy_signatures to accept it)")
+)
+
+// webhookHeaders are the Sapliy headers of a webhook delivery.
+type webhookHeaders struct {
+	EventID   string
+	EventType string
+	Timestamp string
+	Signature string
+}
+
+// readWebhookHeaders reads the Sapliy headers, falling back to the
+// deprecated X-Webhook-* names.
+func readWebhookHeaders(h http.Header) webhookHeaders {
+	get := func(name, deprecated string) string {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+		return h.Get(deprecated)
+	}
+	return webhookHeaders{
+		EventID:   get("X-Sapliy-Event-ID", "X-Webhook-ID"),
+		EventType: get("X-Sapliy-Event-Type", "X-Webhook-Event"),
+		Timestamp: get("X-Sapliy-Timestamp", "X-Webhook-Timestamp"),
+		Signature: get("X-Sapliy-Signature", "X-Webhook-Signature"),
+	}
+}
+
+// webhookSecrets returns the configured signing secrets. webhook_secret
+// holds several, separated by commas, while a secret is being rotated.
+func webhookSecrets() []string {
+	return splitSecrets(viper.GetString("webhook_secret"))
+}
+
+func splitSecrets(v string) []string {
+	var secrets []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+	return secrets
+}
+
+// signatureHeader is a parsed versioned signature header.
+type signatureHeader struct {
+	Timestamp  string
+	Signatures []string
+}
+
+// isVersionedSignature reports whether a signature header has the
+// t=...,v1=... form rather than being a bare legacy signature.
+func isVersionedSignature(v string) bool {
+	for _, part := range strings.Split(v, ",") {
+		if strings.HasPrefix(strings.TrimSpace(part), "t=") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSignatureHeader parses t=...,v1=... Elements of other versions are
+// skipped so that new schemes can be added without breaking receivers.
+func parseSignatureHeader(v string) (signatureHeader, error) {
+	var h signatureHeader
+	for _, part := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return h, fmt.Errorf("malformed signature header element %q", part)
+		}
+		switch key {
+		case "t":
+			h.Timestamp = value
+		case signatureVersion:
+			h.Signatures = append(h.Signatures, value)
+		}
+	}
+	if h.Timestamp == "" {
+		return h, errors.New("signature header has no t= timestamp")
+	}
+	if len(h.Signatures) == 0 {
+		return h, fmt.Errorf("signature header has no %s= signature", signatureVersion)
+	}
+	return h, nil
+}
+
+func (h signatureHeader) String() string {
+	parts := []string{"t=" + h.Timestamp}
+	for _, sig := range h.Signatures {
+		parts = append(parts, signatureVersion+"="+sig)
+	}
+	return strings.Join(parts, ",")
+}
+
+// signedPayload is the content a v1 signature covers.
+func signedPayload(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"."), body...)
+}
+
+// signHeader returns the signature header for a delivery of body made at
+// at, with one signature per secret.
+func signHeader(secrets []string, body []byte, at time.Time) signatureHeader {
+	h := signatureHeader{Timestamp: strconv.FormatInt(at.Unix(), 10)}
+	for _, secret := range secrets {
+		h.Signatures = append(h.Signatures, computeSignature(secret, signedPayload(h.Timestamp, body)))
+	}
+	return h
+}
+
+// signDelivery sets the Sapliy timestamp and signature headers on a
+// delivery of body.
+func signDelivery(h http.Header, secrets []string, body []byte, at time.Time) {
+	sig := signHeader(secrets, body, at)
+	h.Set("X-Sapliy-Timestamp", sig.Timestamp)
+	h.Set("X-Sapliy-Signature", sig.String())
+}
+
+// computeSignature returns the hex signature of content under secret.
+func computeSignature(secret string, content []byte) string {
+	return hex.EncodeToString(signatureMAC(secret, content))
+}
+
+func signatureMAC(secret string, content []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(content)
+	return h.Sum(nil)
+}
+
+// signatureMatches reports whether any of the hex signatures is the
+// signature of content under any of the secrets. Every pair is compared, in
+// constant time, so timing reveals neither which secret nor which signature
+// matched.
+func signatureMatches(secrets []string, content []byte, signatures []string) bool {
+	matched := false
+	for _, secret := range secrets {
+		mac := signatureMAC(secret, content)
+		for _, sig := range signatures {
+			got, err := hex.DecodeString(strings.TrimSpace(sig))
+			if err == nil && hmac.Equal(got, mac) {
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// deliveryVerifier checks the signature and timestamp of incoming
+// deliveries.
+type deliveryVerifier struct {
+	Secrets     []string
+	Tolerance   time.Duration
+	AllowLegacy bool
+}
+
+// verify returns nil if the delivery was signed with one of the secrets and
+// its timestamp is within tolerance of now. legacy reports whether the
+// delivery used the legacy signature scheme.
+func (v deliveryVerifier) verify(h webhookHeaders, body []byte, now time.Time) (legacy bool, err error) {
+	if h.Signature == "" {
+		return false, errNoSignature
+	}
+
+	if !isVersionedSignature(h.Signature) {
+		if !v.AllowLegacy {
+			return true, errLegacySignature
+		}
+		if !signatureMatches(v.Secrets, body, []string{h.Signature}) {
+			return true, errSignatureInvalid
+		}
+		// The timestamp isn't signed, but a stale one is still suspect.
+		if h.Timestamp == "" {
+			return true, nil
+		}
+		ts, err := parseSignatureTimestamp(h.Timestamp)
+		if err != nil {
+			return true, err
+		}
+		return true, checkSignatureTimestamp(ts, now, v.Tolerance)
+	}
+
+	sig, err := parseSignatureHeader(h.Signature)
+	if err != nil {
+		return false, err
+	}
+	secs, err := strconv.ParseInt(sig.Timestamp, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid signed timestamp %q", sig.Timestamp)
+	}
+	if !signatureMatches(v.Secrets, signedPayload(sig.Timestamp, body), sig.Signatures) {
+		return false, errSignatureInvalid
+	}
+	if h.Timestamp != "" && h.Timestamp != sig.Timestamp {
+		return false, fmt.Errorf("timestamp header %s differs from the signed timestamp %s", h.Timestamp, sig.Timestamp)
+	}
+	return false, checkSignatureTimestamp(time.Unix(secs, 0), now, v.Tolerance)
+}
+
+// parseSignatureTimestamp parses a delivery timestamp: Unix seconds, as sent
+// in the timestamp header, or RFC 3339.
+func parseSignatureTimestamp(ts string) (time.Time, error) {
+	ts = strings.TrimSpace(ts)
+	if secs, err := strconv.ParseInt(ts, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q (use Unix seconds or RFC 3339)", ts)
+}
+
+// checkSignatureTimestamp returns an error explaining why a delivery made at
+// ts would be rejected at now, or nil if it is within tolerance.
+func checkSignatureTimestamp(ts, now time.Time, tolerance time.Duration) error {
+	switch age := now.Sub(ts); {
+	case age > tolerance:
+		return fmt.Errorf("timestamp is %s old, beyond the %s tolerance; receivers reject it as a possible replay",
+			age.Round(time.Second), tolerance)
+	case -age > tolerance:
+		return fmt.Errorf("timestamp is %s in the future, beyond the %s tolerance; the sender's or receiver's clock is off",
+			(-age).Round(time.Second), tolerance)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseSignatureHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    signatureHeader
+		wantErr bool
+	}{
+		{
+			name:   "single signature",
+			header: "t=1700000000,v1=abc",
+			want:   signatureHeader{Timestamp: "1700000000", Signatures: []string{"abc"}},
+		},
+		{
+			name:   "one signature per secret",
+			header: "t=1700000000,v1=abc,v1=def",
+			want:   signatureHeader{Timestamp: "1700000000", Signatures: []string{"abc", "def"}},
+		},
+		{
+			name:   "spaces and unknown versions",
+			header: " t=1700000000 , v0=old , v1=abc , v2=new",
+			want:   signatureHeader{Timestamp: "1700000000", Signatures: []string{"abc"}},
+		},
+		{name: "no timestamp", header: "v1=abc", wantErr: true},
+		{name: "no v1 signature", header: "t=1700000000,v2=abc", wantErr: true},
+		{name: "malformed element", header: "t=1700000000,v1", wantErr: true},
+		{name: "empty", header: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignatureHeader(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSignatureHeader(%q) = %+v, want error", tt.header, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSignatureHeader(%q): %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSignatureHeader(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureHeaderRoundTrip(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	h := signHeader([]string{"whsec_a", "whsec_b"}, []byte(`{"id":"evt_1"}`), at)
+	got, err := parseSignatureHeader(h.String())
+	if err != nil {
+		t.Fatalf("parse %q: %v", h.String(), err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("round trip = %+v, want %+v", got, h)
+	}
+	if len(h.Signatures) != 2 || h.Signatures[0] == h.Signatures[1] {
+		t.Errorf("want one distinct signature per secret, got %v", h.Signatures)
+	}
+}
+
+func TestIsVersionedSignature(t *testing.T) {
+	tests := map[string]bool{
+		"t=1700000000,v1=abc": true,
+		"v1=abc, t=1":         true,
+		"abc123":              false,
+		"":                    false,
+	}
+	for header, want := range tests {
+		if got := isVersionedSignature(header); got != want {
+			t.Errorf("isVersionedSignature(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestDeliveryVerifierVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","type":"payment.succeeded"}`)
+	versioned := func(secrets []string, at time.Time) webhookHeaders {
+		sig := signHeader(secrets, body, at)
+		return webhookHeaders{Timestamp: sig.Timestamp, Signature: sig.String()}
+	}
+	legacy := func(secret string, ts string) webhookHeaders {
+		return webhookHeaders{Timestamp: ts, Signature: computeSignature(secret, body)}
+	}
+	unix := func(at time.Time) string { return strconv.FormatInt(at.Unix(), 10) }
+
+	rotating := deliveryVerifier{Secrets: []string{"whsec_old", "whsec_new"}, Tolerance: 5 * time.Minute}
+	withLegacy := rotating
+	withLegacy.AllowLegacy = true
+
+	tests := []struct {
+		name       string
+		verifier   deliveryVerifier
+		headers    webhookHeaders
+		body       []byte
+		wantLegacy bool
+		wantErr    error // nil, a sentinel, or errAny
+	}{
+		{name: "signed with the new secret", verifier: rotating, headers: versioned([]string{"whsec_new"}, now)},
+		{name: "signed with the old secret", verifier: rotating, headers: versioned([]string{"whsec_old"}, now)},
+		{name: "signed with both secrets", verifier: rotating, headers: versioned([]string{"whsec_old", "whsec_new"}, now)},
+		{name: "one of several signatures matches", verifier: rotating, headers: versioned([]string{"whsec_other", "whsec_new"}, now)},
+		{name: "unknown secret", verifier: rotating, headers: versioned([]string{"whsec_other"}, now), wantErr: errSignatureInvalid},
+		{name: "tampered body", verifier: rotating, headers: versioned([]string{"whsec_new"}, now), body: []byte(`{"id":"evt_2"}`), wantErr: errSignatureInvalid},
+		{name: "within tolerance", verifier: rotating, headers: versioned([]string{"whsec_new"}, now.Add(-4*time.Minute))},
+		{name: "stale timestamp", verifier: rotating, headers: versioned([]string{"whsec_new"}, now.Add(-6*time.Minute)), wantErr: errAny},
+		{name: "future timestamp", verifier: rotating, headers: versioned([]string{"whsec_new"}, now.Add(6*time.Minute)), wantErr: errAny},
+		{
+			name:     "timestamp header differs from the signed one",
+			verifier: rotating,
+			headers: func() webhookHeaders {
+				h := versioned([]string{"whsec_new"}, now)
+				h.Timestamp = unix(now.Add(time.Second))
+				return h
+			}(),
+			wantErr: errAny,
+		},
+		{name: "no signature", verifier: rotating, headers: webhookHeaders{Timestamp: unix(now)}, wantErr: errNoSignature},
+		{name: "legacy rejected by default", verifier: rotating, headers: legacy("whsec_new", unix(now)), wantLegacy: true, wantErr: errLegacySignature},
+		{name: "legacy allowed", verifier: withLegacy, headers: legacy("whsec_old", unix(now)), wantLegacy: true},
+		{name: "legacy allowed without timestamp", verifier: withLegacy, headers: legacy("whsec_old", ""), wantLegacy: true},
+		{name: "legacy with unknown secret", verifier: withLegacy, headers: legacy("whsec_other", unix(now)), wantLegacy: true, wantErr: errSignatureInvalid},
+		{name: "legacy with stale timestamp", verifier: withLegacy, headers: legacy("whsec_old", unix(now.Add(-time.Hour))), wantLegacy: true, wantErr: errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := body
+			if tt.body != nil {
+				b = tt.body
+			}
+			gotLegacy, err := tt.verifier.verify(tt.headers, b, now)
+			if gotLegacy != tt.wantLegacy {
+				t.Errorf("legacy = %v, want %v", gotLegacy, tt.wantLegacy)
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("verify: unexpected error %v", err)
+			case tt.wantErr == errAny && err == nil:
+				t.Errorf("verify: want an error, got nil")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Errorf("verify: error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errAny marks test cases that expect some error without a sentinel.
+var errAny = errors.New("any error")
+
+func TestCheckSignatureTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		age     time.Duration
+		wantErr bool
+	}{
+		{0, false},
+		{5 * time.Minute, false},
+		{-5 * time.Minute, false},
+		{5*time.Minute + time.Second, true},
+		{-5*time.Minute - time.Second, true},
+	}
+	for _, tt := range tests {
+		err := checkSignatureTimestamp(now.Add(-tt.age), now, 5*time.Minute)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("age %s: err = %v, want error %v", tt.age, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSplitSecrets(t *testing.T) {
+	tests := map[string][]string{
+		"":                      nil,
+		"whsec_a":               {"whsec_a"},
+		"whsec_a,whsec_b":       {"whsec_a", "whsec_b"},
+		" whsec_a , ,whsec_b ,": {"whsec_a", "whsec_b"},
+	}
+	for in, want := range tests {
+		if got := splitSecrets(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitSecrets(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
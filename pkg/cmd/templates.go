@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 
 	fintech "github.com/sapliy/fintech-sdk-go"
@@ -12,6 +12,31 @@ import (
 	"github.com/spf13/viper"
 )
 
+type templateSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Flows       int    `json:"flows"`
+	Webhooks    int    `json:"webhooks"`
+}
+
+type templateDetails struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Flows       []string `json:"flows"`
+	Webhooks    []string `json:"webhooks"`
+	Events      []string `json:"events"`
+}
+
+type templateApplyResult struct {
+	Template string `json:"template"`
+	ZoneID   string `json:"zone_id,omitempty"`
+	ZoneName string `json:"zone_name"`
+	Mode     string `json:"mode"`
+	Flows    int    `json:"flows"`
+	Webhooks int    `json:"webhooks"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
 var templatesCmd = &cobra.Command{
 	Use:   "templates",
 	Short: "Manage zone templates",
@@ -22,13 +47,8 @@ Templates include pre-configured flows, webhook endpoints, and default settings.
 var templatesListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available zone templates",
-	Run: func(cmd *cobra.Command, args []string) {
-		templates := []struct {
-			Name        string
-			Description string
-			Flows       int
-			Webhooks    int
-		}{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templates := []templateSummary{
 			{"e-commerce", "Complete e-commerce solution with checkout, payments, and order tracking", 3, 2},
 			{"saas-billing", "Subscription and usage-based billing for SaaS products", 2, 1},
 			{"marketplace", "Multi-vendor marketplace with escrow and fee management", 4, 3},
@@ -36,17 +56,24 @@ var templatesListCmd = &cobra.Command{
 			{"automation-hub", "Event-driven automation without payment processing", 1, 1},
 		}
 
-		fmt.Println("📋 Available Zone Templates")
-		fmt.Println(strings.Repeat("─", 70))
-		fmt.Printf("%-18s %-40s %s  %s\n", "NAME", "DESCRIPTION", "FLOWS", "WEBHOOKS")
-		fmt.Println(strings.Repeat("─", 70))
+		return printList(templates, []column[templateSummary]{
+			{"NAME", func(t templateSummary) string { return t.Name }},
+			{"DESCRIPTION", func(t templateSummary) string { return t.Description }},
+			{"FLOWS", func(t templateSummary) string { return strconv.Itoa(t.Flows) }},
+			{"WEBHOOKS", func(t templateSummary) string { return strconv.Itoa(t.Webhooks) }},
+		}, func() {
+			fmt.Fprintln(stdout, "📋 Available Zone Templates")
+			fmt.Fprintln(stdout, strings.Repeat("─", 70))
+			fmt.Fprintf(stdout, "%-18s %-40s %s  %s\n", "NAME", "DESCRIPTION", "FLOWS", "WEBHOOKS")
+			fmt.Fprintln(stdout, strings.Repeat("─", 70))
 
-		for _, t := range templates {
-			fmt.Printf("%-18s %-40s %3d    %3d\n", t.Name, t.Description, t.Flows, t.Webhooks)
-		}
+			for _, t := range templates {
+				fmt.Fprintf(stdout, "%-18s %-40s %3d    %3d\n", t.Name, t.Description, t.Flows, t.Webhooks)
+			}
 
-		fmt.Println()
-		fmt.Println("Use 'sapliy templates apply <name>' to apply a template to a zone.")
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout, "Use 'sapliy templates apply <name>' to apply a template to a zone.")
+		})
 	},
 }
 
@@ -54,11 +81,10 @@ var templatesApplyCmd = &cobra.Command{
 	Use:   "apply [template_name]",
 	Short: "Apply a template to a zone",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set. Use 'sapliy auth login'.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
 		}
 
 		templateName := args[0]
@@ -70,35 +96,42 @@ var templatesApplyCmd = &cobra.Command{
 			zoneName = fmt.Sprintf("%s-zone", templateName)
 		}
 
-		fmt.Printf("🎨 Applying template '%s' to new zone '%s' (%s mode)\n", templateName, zoneName, mode)
-		fmt.Println(strings.Repeat("─", 60))
+		fmt.Fprintf(progress(), "🎨 Applying template '%s' to new zone '%s' (%s mode)\n", templateName, zoneName, mode)
+		fmt.Fprintln(progress(), strings.Repeat("─", 60))
 
 		if dryRun {
-			fmt.Println("🏃 Dry run - would create:")
-			fmt.Printf("   Zone: %s\n", zoneName)
-			fmt.Printf("   Mode: %s\n", mode)
-			fmt.Printf("   Template: %s\n", templateName)
-			return
+			plan := templateApplyResult{Template: templateName, ZoneName: zoneName, Mode: mode, DryRun: true}
+			return printObject(plan, func() {
+				fmt.Fprintln(stdout, "🏃 Dry run - would create:")
+				fmt.Fprintf(stdout, "   Zone: %s\n", zoneName)
+				fmt.Fprintf(stdout, "   Mode: %s\n", mode)
+				fmt.Fprintf(stdout, "   Template: %s\n", templateName)
+			})
 		}
 
-		client := fintech.NewClient(apiKey, fintech.WithBaseURL(viper.GetString("api_url")))
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
 		orgID := viper.GetString("org_id")
 
 		// Step 1: Create the zone
-		fmt.Print("Creating zone... ")
-		zone, err := client.Zones.Create(context.Background(), &fintech.CreateZoneRequest{
+		fmt.Fprint(progress(), "Creating zone... ")
+		key := commandIdempotencyKey(cmd)
+		ctx := withIdempotencyKey(context.Background(), key)
+		zone, err := client.Zones.Create(ctx, &fintech.CreateZoneRequest{
 			OrgID: orgID,
 			Name:  zoneName,
 			Mode:  mode,
 		})
 		if err != nil {
-			fmt.Printf("❌\n   Error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(progress(), "❌\n")
+			return withIdempotencyKeyHint(fmt.Errorf("create zone: %w", err), key)
 		}
-		fmt.Printf("✅ %s\n", zone.ID)
+		fmt.Fprintf(progress(), "✅ %s\n", zone.ID)
 
 		// Step 2: Display template info (actual template application would be done server-side)
-		fmt.Print("Configuring template... ")
+		fmt.Fprint(progress(), "Configuring template... ")
 		templateFlows := map[string]int{
 			"e-commerce":     3,
 			"saas-billing":   2,
@@ -115,22 +148,32 @@ var templatesApplyCmd = &cobra.Command{
 		}
 		flows := templateFlows[templateName]
 		webhooks := templateWebhooks[templateName]
-		fmt.Println("✅")
+		fmt.Fprintln(progress(), "✅")
 
-		fmt.Println()
-		fmt.Println("📦 Template Applied Successfully!")
-		fmt.Println(strings.Repeat("─", 60))
-		fmt.Printf("Zone ID:         %s\n", zone.ID)
-		fmt.Printf("Zone Name:       %s\n", zoneName)
-		fmt.Printf("Mode:            %s\n", mode)
-		fmt.Printf("API Keys:        Available in zone settings\n")
-		fmt.Println()
-		fmt.Printf("Configured %d flow(s) and %d webhook endpoint(s)\n", flows, webhooks)
-		fmt.Println()
-		fmt.Println("Next steps:")
-		fmt.Printf("  1. Switch to zone: sapliy zones switch %s\n", zone.ID)
-		fmt.Println("  2. List flows: sapliy flows list")
-		fmt.Println("  3. Start debugging: sapliy debug listen")
+		result := templateApplyResult{
+			Template: templateName,
+			ZoneID:   zone.ID,
+			ZoneName: zoneName,
+			Mode:     mode,
+			Flows:    flows,
+			Webhooks: webhooks,
+		}
+		return printObject(result, func() {
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout, "📦 Template Applied Successfully!")
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			fmt.Fprintf(stdout, "Zone ID:         %s\n", zone.ID)
+			fmt.Fprintf(stdout, "Zone Name:       %s\n", zoneName)
+			fmt.Fprintf(stdout, "Mode:            %s\n", mode)
+			fmt.Fprintf(stdout, "API Keys:        Available in zone settings\n")
+			fmt.Fprintln(stdout)
+			fmt.Fprintf(stdout, "Configured %d flow(s) and %d webhook endpoint(s)\n", flows, webhooks)
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout, "Next steps:")
+			fmt.Fprintf(stdout, "  1. Switch to zone: sapliy zones switch %s\n", zone.ID)
+			fmt.Fprintln(stdout, "  2. List flows: sapliy flows list")
+			fmt.Fprintln(stdout, "  3. Start debugging: sapliy debug listen")
+		})
 	},
 }
 
@@ -138,16 +181,11 @@ var templatesShowCmd = &cobra.Command{
 	Use:   "show [template_name]",
 	Short: "Show details of a template",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		templateName := args[0]
 
 		// Template details
-		templates := map[string]struct {
-			Description string
-			Flows       []string
-			Webhooks    []string
-			Events      []string
-		}{
+		templates := map[string]templateDetails{
 			"e-commerce": {
 				Description: "Complete e-commerce solution with checkout, payments, and order tracking",
 				Flows: []string{
@@ -235,27 +273,32 @@ var templatesShowCmd = &cobra.Command{
 
 		tmpl, ok := templates[templateName]
 		if !ok {
-			fmt.Printf("❌ Template '%s' not found. Use 'sapliy templates list' to see available templates.\n", templateName)
-			os.Exit(1)
+			e := newCLIError(kindNotFound, "template '%s' not found", templateName)
+			e.Hint = "run 'sapliy templates list' to see available templates"
+			return e
 		}
 
-		fmt.Printf("📋 Template: %s\n", templateName)
-		fmt.Println(strings.Repeat("─", 60))
-		fmt.Printf("Description: %s\n\n", tmpl.Description)
+		tmpl.Name = templateName
 
-		fmt.Println("Flows:")
-		for _, f := range tmpl.Flows {
-			fmt.Printf("  • %s\n", f)
-		}
+		return printObject(tmpl, func() {
+			fmt.Fprintf(stdout, "📋 Template: %s\n", templateName)
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			fmt.Fprintf(stdout, "Description: %s\n\n", tmpl.Description)
 
-		fmt.Println("\nWebhook Endpoints:")
-		for _, w := range tmpl.Webhooks {
-			fmt.Printf("  • %s\n", w)
-		}
+			fmt.Fprintln(stdout, "Flows:")
+			for _, f := range tmpl.Flows {
+				fmt.Fprintf(stdout, "  • %s\n", f)
+			}
 
-		fmt.Println("\nEvent Types:")
-		eventsJSON, _ := json.MarshalIndent(tmpl.Events, "  ", "  ")
-		fmt.Printf("  %s\n", string(eventsJSON))
+			fmt.Fprintln(stdout, "\nWebhook Endpoints:")
+			for _, w := range tmpl.Webhooks {
+				fmt.Fprintf(stdout, "  • %s\n", w)
+			}
+
+			fmt.Fprintln(stdout, "\nEvent Types:")
+			eventsJSON, _ := json.MarshalIndent(tmpl.Events, "  ", "  ")
+			fmt.Fprintf(stdout, "  %s\n", string(eventsJSON))
+		})
 	},
 }
 
@@ -268,4 +311,5 @@ func init() {
 	templatesApplyCmd.Flags().StringP("zone-name", "n", "", "Name for the new zone")
 	templatesApplyCmd.Flags().StringP("mode", "m", "test", "Zone mode (test/live)")
 	templatesApplyCmd.Flags().Bool("dry-run", false, "Show what would be created without doing it")
+	addIdempotencyKeyFlag(templatesApplyCmd)
 }
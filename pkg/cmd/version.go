@@ -13,7 +13,12 @@ func init() {
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of Sapliy CLI",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Sapliy CLI v%s\n", rootCmd.Version)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := struct {
+			Version string `json:"version"`
+		}{rootCmd.Version}
+		return printObject(info, func() {
+			fmt.Fprintf(stdout, "Sapliy CLI v%s\n", rootCmd.Version)
+		})
 	},
 }
@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Webhook endpoints are managed with apiRequest: the SDK's endpoint calls are
+// not scoped to a zone and cannot update, delete or rotate.
+
+const defaultSecretOverlap = 24 * time.Hour
+
+// webhookEndpoint is a URL that receives a zone's events.
+type webhookEndpoint struct {
+	ID                      string     `json:"id"`
+	URL                     string     `json:"url"`
+	Description             string     `json:"description,omitempty"`
+	EnabledEvents           []string   `json:"enabledEvents"`
+	Status                  string     `json:"status"`
+	Secret                  string     `json:"secret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt,omitempty"`
+	CreatedAt               *time.Time `json:"createdAt,omitempty"`
+}
+
+// endpointList is GET /v1/webhooks/endpoints, with or without an envelope.
+type endpointList struct {
+	Data []webhookEndpoint `json:"data"`
+}
+
+func (l *endpointList) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		return json.Unmarshal(data, &l.Data)
+	}
+	type list endpointList
+	return json.Unmarshal(data, (*list)(l))
+}
+
+// eventPatternRe matches the patterns an endpoint can subscribe to: an event
+// type, a prefix wildcard such as "payment.*", or "*".
+var eventPatternRe = regexp.MustCompile(`^(\*|[a-z0-9_]+(\.[a-z0-9_]+)*(\.\*)?)$`)
+
+func validateEventPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if !eventPatternRe.MatchString(p) {
+			return validationError("invalid event pattern %q (use e.g. payment.succeeded, payment.* or *)", p)
+		}
+	}
+	return nil
+}
+
+// endpointPath returns the API path of an endpoint, or of the collection
+// when id is empty.
+func endpointPath(id, zone string) string {
+	q := url.Values{}
+	q.Set("zone_id", zone)
+	path := "/v1/webhooks/endpoints"
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+	return path + "?" + q.Encode()
+}
+
+var webhookEndpointsCmd = &cobra.Command{
+	Use:     "endpoints",
+	Aliases: []string{"endpoint"},
+	Short:   "Manage webhook endpoints",
+	Long: `Register, change and remove the URLs that receive a zone's webhook events,
+and rotate their signing secrets.`,
+}
+
+var listEndpointsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhook endpoints in the zone",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+		zone, err := webhookZone()
+		if err != nil {
+			return err
+		}
+
+		var endpoints endpointList
+		if err := apiRequest(context.Background(), http.MethodGet, endpointPath("", zone), nil, &endpoints); err != nil {
+			return fmt.Errorf("list endpoints: %w", err)
+		}
+
+		return printList(endpoints.Data, []column[webhookEndpoint]{
+			{"ID", func(e webhookEndpoint) string { return e.ID }},
+			{"URL", func(e webhookEndpoint) string { return e.URL }},
+			{"EVENTS", func(e webhookEndpoint) string { return strings.Join(e.EnabledEvents, ",") }},
+			{"STATUS", func(e webhookEndpoint) string { return e.Status }},
+			{"DESCRIPTION", func(e webhookEndpoint) string { return e.Description }},
+		}, func() {
+			if len(endpoints.Data) == 0 {
+				fmt.Fprintln(stdout, "No webhook endpoints in this zone.")
+				fmt.Fprintln(stdout, "Create one with: sapliy webhooks endpoints create --url <url>")
+				return
+			}
+			fmt.Fprintf(stdout, "%-24s %-40s %-10s %s\n", "ID", "URL", "STATUS", "EVENTS")
+			fmt.Fprintln(stdout, strings.Repeat("─", 90))
+			for _, e := range endpoints.Data {
+				fmt.Fprintf(stdout, "%-24s %-40s %-10s %s\n",
+					e.ID, truncate(e.URL, 40), valueOrDash(e.Status), strings.Join(e.EnabledEvents, ","))
+			}
+		})
+	},
+}
+
+var createEndpointCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Register a webhook endpoint",
+	Example: `  sapliy webhooks endpoints create --url https://example.com/webhooks
+  sapliy webhooks endpoints create --url https://example.com/payments --events 'payment.*,refund.created'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+		zone, err := webhookZone()
+		if err != nil {
+			return err
+		}
+
+		endpointURL, _ := cmd.Flags().GetString("url")
+		events, _ := cmd.Flags().GetStringSlice("events")
+		description, _ := cmd.Flags().GetString("description")
+		disabled, _ := cmd.Flags().GetBool("disabled")
+		if err := validateEndpointURL(endpointURL); err != nil {
+			return err
+		}
+		if err := validateEventPatterns(events); err != nil {
+			return err
+		}
+
+		body := map[string]interface{}{
+			"zone_id":       zone,
+			"url":           endpointURL,
+			"enabledEvents": events,
+			"description":   description,
+			"status":        "enabled",
+		}
+		if disabled {
+			body["status"] = "disabled"
+		}
+
+		key := commandIdempotencyKey(cmd)
+		var endpoint webhookEndpoint
+		err = apiRequest(withIdempotencyKey(context.Background(), key), http.MethodPost, endpointPath("", zone), body, &endpoint)
+		if err != nil {
+			return withIdempotencyKeyHint(fmt.Errorf("create endpoint: %w", err), key)
+		}
+
+		return revealEndpointSecret(cmd, &endpoint, func() {
+			fmt.Fprintf(stdout, "✅ Webhook endpoint created: %s → %s\n", endpoint.ID, endpoint.URL)
+			fmt.Fprintf(stdout, "Events:      %s\n", strings.Join(endpoint.EnabledEvents, ", "))
+			fmt.Fprintf(stdout, "Status:      %s\n", endpoint.Status)
+		})
+	},
+}
+
+var updateEndpointCmd = &cobra.Command{
+	Use:   "update [endpoint_id]",
+	Short: "Change a webhook endpoint",
+	Example: `  sapliy webhooks endpoints update we_123 --events 'payment.*'
+  sapliy webhooks endpoints update we_123 --disable`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+		zone, err := webhookZone()
+		if err != nil {
+			return err
+		}
+
+		body := map[string]interface{}{}
+		if cmd.Flags().Changed("url") {
+			endpointURL, _ := cmd.Flags().GetString("url")
+			if err := validateEndpointURL(endpointURL); err != nil {
+				return err
+			}
+			body["url"] = endpointURL
+		}
+		if cmd.Flags().Changed("events") {
+			events, _ := cmd.Flags().GetStringSlice("events")
+			if err := validateEventPatterns(events); err != nil {
+				return err
+			}
+			body["enabledEvents"] = events
+		}
+		if cmd.Flags().Changed("description") {
+			body["description"], _ = cmd.Flags().GetString("description")
+		}
+		if enable, _ := cmd.Flags().GetBool("enable"); enable {
+			body["status"] = "enabled"
+		}
+		if disable, _ := cmd.Flags().GetBool("disable"); disable {
+			body["status"] = "disabled"
+		}
+		if len(body) == 0 {
+			return validationError("nothing to update; pass --url, --events, --description, --enable or --disable")
+		}
+
+		var endpoint webhookEndpoint
+		if err := apiRequest(context.Background(), http.MethodPatch, endpointPath(args[0], zone), body, &endpoint); err != nil {
+			return fmt.Errorf("update endpoint %s: %w", args[0], err)
+		}
+
+		return printObject(endpoint, func() {
+			fmt.Fprintf(stdout, "✅ Webhook endpoint updated: %s → %s\n", endpoint.ID, endpoint.URL)
+			fmt.Fprintf(stdout, "Events:      %s\n", strings.Join(endpoint.EnabledEvents, ", "))
+			fmt.Fprintf(stdout, "Status:      %s\n", endpoint.Status)
+		})
+	},
+}
+
+var deleteEndpointCmd = &cobra.Command{
+	Use:   "delete [endpoint_id]",
+	Short: "Delete a webhook endpoint",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+		zone, err := webhookZone()
+		if err != nil {
+			return err
+		}
+
+		endpointID := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			fmt.Fprintf(progress(), "Delete webhook endpoint %s? It will stop receiving events. [y/N]: ", endpointID)
+			var response string
+			fmt.Scanln(&response)
+			if strings.ToLower(response) != "y" {
+				fmt.Fprintln(progress(), "Cancelled.")
+				return nil
+			}
+		}
+
+		if err := apiRequest(context.Background(), http.MethodDelete, endpointPath(endpointID, zone), nil, nil); err != nil {
+			return fmt.Errorf("delete endpoint %s: %w", endpointID, err)
+		}
+
+		result := map[string]interface{}{"id": endpointID, "deleted": true}
+		return printObject(result, func() {
+			fmt.Fprintf(stdout, "✅ Webhook endpoint deleted: %s\n", endpointID)
+		})
+	},
+}
+
+var rotateEndpointSecretCmd = &cobra.Command{
+	Use:   "rotate-secret [endpoint_id]",
+	Short: "Issue a new signing secret for a webhook endpoint",
+	Long: `Issue a new signing secret for a webhook endpoint.
+
+During the --overlap window the previous secret stays valid, so the receiving
+server can be updated without rejecting deliveries. The new secret is shown
+only once; --save also stores it as webhook_secret in the active profile so
+'sapliy listen' verifies signatures with it.`,
+	Example: `  sapliy webhooks endpoints rotate-secret we_123
+  sapliy webhooks endpoints rotate-secret we_123 --overlap 2h --save`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+		zone, err := webhookZone()
+		if err != nil {
+			return err
+		}
+
+		endpointID := args[0]
+		overlap, _ := cmd.Flags().GetDuration("overlap")
+		if overlap < 0 {
+			return validationError("--overlap must not be negative")
+		}
+
+		q := url.Values{}
+		q.Set("zone_id", zone)
+		path := "/v1/webhooks/endpoints/" + url.PathEscape(endpointID) + "/rotate-secret?" + q.Encode()
+		body := map[string]interface{}{"overlapSeconds": int64(overlap / time.Second)}
+
+		key := commandIdempotencyKey(cmd)
+		var endpoint webhookEndpoint
+		err = apiRequest(withIdempotencyKey(context.Background(), key), http.MethodPost, path, body, &endpoint)
+		if err != nil {
+			return withIdempotencyKeyHint(fmt.Errorf("rotate secret for %s: %w", endpointID, err), key)
+		}
+		if endpoint.Secret == "" {
+			return fmt.Errorf("rotate secret for %s: the API did not return a new secret", endpointID)
+		}
+
+		return revealEndpointSecret(cmd, &endpoint, func() {
+			fmt.Fprintf(stdout, "✅ Signing secret rotated for %s\n", endpoint.ID)
+			if endpoint.PreviousSecretExpiresAt != nil {
+				fmt.Fprintf(stdout, "The previous secret stays valid until %s.\n",
+					endpoint.PreviousSecretExpiresAt.Local().Format("Jan 02 15:04"))
+			} else {
+				fmt.Fprintln(stdout, "The previous secret no longer verifies deliveries.")
+			}
+		})
+	},
+}
+
+// revealEndpointSecret prints an endpoint that carries a newly issued secret.
+// The secret is shown unmasked because this is the only time it can be seen;
+// afterwards it is masked like any other. With --save it is stored as
+// webhook_secret in the active profile.
+func revealEndpointSecret(cmd *cobra.Command, endpoint *webhookEndpoint, table func()) error {
+	secret := endpoint.Secret
+	err := printObjectTo(unmaskedStdout, endpoint, func() {
+		table()
+		if secret == "" {
+			return
+		}
+		fmt.Fprintln(stdout, "\nSigning secret (shown only once, store it now):")
+		fmt.Fprintf(unmaskedStdout, "\n    %s\n\n", secret)
+	})
+	if err != nil || secret == "" {
+		return err
+	}
+	registerSecret(secret)
+
+	if save, _ := cmd.Flags().GetBool("save"); !save {
+		fmt.Fprintln(progress(), "Pass --save to store it as webhook_secret for 'sapliy listen'.")
+		return nil
+	}
+	if err := saveProfileValue("webhook_secret", secret); err != nil {
+		return fmt.Errorf("save webhook_secret: %w", err)
+	}
+	fmt.Fprintf(progress(), "Saved as webhook_secret in profile %s.\n", activeProfileName())
+	if _, set := os.LookupEnv("SAPLIY_WEBHOOK_SECRET"); set {
+		fmt.Fprintln(progress(), "Note: SAPLIY_WEBHOOK_SECRET is set and takes precedence over the saved value.")
+	}
+	return nil
+}
+
+func validateEndpointURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return validationError("invalid endpoint URL %q (must be an http or https URL)", raw)
+	}
+	return nil
+}
+
+func init() {
+	webhooksCmd.AddCommand(webhookEndpointsCmd)
+	webhookEndpointsCmd.AddCommand(listEndpointsCmd)
+	webhookEndpointsCmd.AddCommand(createEndpointCmd)
+	webhookEndpointsCmd.AddCommand(updateEndpointCmd)
+	webhookEndpointsCmd.AddCommand(deleteEndpointCmd)
+	webhookEndpointsCmd.AddCommand(rotateEndpointSecretCmd)
+
+	createEndpointCmd.Flags().String("url", "", "URL that receives the events")
+	createEndpointCmd.Flags().StringSlice("events", []string{"*"}, "Event types to send, comma separated; 'payment.*' matches every payment event")
+	createEndpointCmd.Flags().String("description", "", "Description shown in the Studio")
+	createEndpointCmd.Flags().Bool("disabled", false, "Create the endpoint disabled")
+	createEndpointCmd.Flags().Bool("save", false, "Store the signing secret as webhook_secret in the active profile")
+	createEndpointCmd.MarkFlagRequired("url")
+	addIdempotencyKeyFlag(createEndpointCmd)
+
+	updateEndpointCmd.Flags().String("url", "", "New URL")
+	updateEndpointCmd.Flags().StringSlice("events", nil, "Replace the subscribed event types")
+	updateEndpointCmd.Flags().String("description", "", "New description")
+	updateEndpointCmd.Flags().Bool("enable", false, "Resume deliveries to the endpoint")
+	updateEndpointCmd.Flags().Bool("disable", false, "Pause deliveries to the endpoint")
+	updateEndpointCmd.MarkFlagsMutuallyExclusive("enable", "disable")
+
+	deleteEndpointCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+
+	rotateEndpointSecretCmd.Flags().Duration("overlap", defaultSecretOverlap, "How long the previous secret stays valid (0 to expire it now)")
+	rotateEndpointSecretCmd.Flags().Bool("save", false, "Store the new secret as webhook_secret in the active profile")
+	addIdempotencyKeyFlag(rotateEndpointSecretCmd)
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// staleTimestampAge is how old --stale-timestamp makes a delivery: well past
+// the tolerance receivers are expected to enforce.
+const staleTimestampAge = time.Hour
+
+// sendResult reports a synthetic delivery and the receiver's response.
+type sendResult struct {
+	EventID        string         `json:"event_id"`
+	Type           string         `json:"type"`
+	URL            string         `json:"url"`
+	BadSignature   bool           `json:"bad_signature,omitempty"`
+	StaleTimestamp bool           `json:"stale_timestamp,omitempty"`
+	StatusCode     int            `json:"status_code"`
+	LatencyMS      int64          `json:"latency_ms"`
+	ResponseBody   string         `json:"response_body,omitempty"`
+	Request        attemptRequest `json:"request"`
+}
+
+// deliveryEvent is the body of a webhook delivery.
+type deliveryEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+var webhooksSendCmd = &cobra.Command{
+	Use:   "send <url>",
+	Short: "Sign and deliver a synthetic webhook to a URL",
+	Long: `Build a webhook delivery the way Sapliy does, sign it with the configured
+webhook_secret (or --secret), POST it to the URL and print the response.
+The signature header carries one v1 signature per secret.
+
+--bad-signature and --stale-timestamp send deliberately broken deliveries
+for negative tests. A normal delivery fails unless the receiver answers
+2xx; a broken one fails if the receiver accepts it.`,
+	Example: `  sapliy webhooks send http://localhost:4242/webhook --type payment.succeeded --data @payment.json
+  sapliy webhooks send http://localhost:4242/webhook --type payment.failed --data '{"amount": 2000}'
+  sapliy webhooks send http://localhost:4242/webhook --type payment.succeeded --bad-signature`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := args[0]
+		if err := validateEndpointURL(target); err != nil {
+			return err
+		}
+		eventType, _ := cmd.Flags().GetString("type")
+		eventID, _ := cmd.Flags().GetString("id")
+		dataArg, _ := cmd.Flags().GetString("data")
+		badSignature, _ := cmd.Flags().GetBool("bad-signature")
+		staleTimestamp, _ := cmd.Flags().GetBool("stale-timestamp")
+
+		secrets := webhookSecrets()
+		if cmd.Flags().Changed("secret") {
+			values, _ := cmd.Flags().GetStringArray("secret")
+			secrets = nil
+			for _, v := range values {
+				secrets = append(secrets, splitSecrets(v)...)
+			}
+		}
+		if len(secrets) == 0 && !badSignature {
+			e := newCLIError(kindValidation, "no signing secret")
+			e.Hint = "pass --secret or run 'sapliy config set webhook_secret <secret>'"
+			return e
+		}
+		for _, secret := range secrets {
+			registerSecret(secret)
+		}
+
+		data, err := readEventData(dataArg)
+		if err != nil {
+			return err
+		}
+		if eventID == "" {
+			eventID = newEventID()
+		}
+
+		now := time.Now()
+		body, err := json.Marshal(deliveryEvent{ID: eventID, Type: eventType, Data: data, CreatedAt: now.UTC().Truncate(time.Second)})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return validationError("invalid URL %q: %v", target, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Sapliy-Event-ID", eventID)
+		req.Header.Set("X-Sapliy-Event-Type", eventType)
+		signedAt := now
+		if staleTimestamp {
+			signedAt = now.Add(-staleTimestampAge)
+		}
+		if badSignature {
+			// Signed with a throwaway secret: well formed, but never valid.
+			secrets = []string{newThrowawaySecret()}
+		}
+		signDelivery(req.Header, secrets, body, signedAt)
+
+		result := sendResult{
+			EventID:        eventID,
+			Type:           eventType,
+			URL:            target,
+			BadSignature:   badSignature,
+			StaleTimestamp: staleTimestamp,
+			Request: attemptRequest{
+				Method:  req.Method,
+				URL:     target,
+				Headers: map[string]string{},
+				Body:    string(body),
+			},
+		}
+		for name := range req.Header {
+			result.Request.Headers[name] = req.Header.Get(name)
+		}
+
+		client, err := webhookHTTPClient(requestTimeout())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(progress(), "📤 Sending %s (%s) to %s\n", eventType, eventID, target)
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			e := newCLIError(kindNetwork, "send to %s: %v", target, err)
+			e.Hint = "check that the receiver is running and reachable"
+			return e
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		result.LatencyMS = time.Since(start).Milliseconds()
+		result.StatusCode = resp.StatusCode
+		result.ResponseBody = string(respBody)
+
+		broken := badSignature || staleTimestamp
+		accepted := resp.StatusCode >= 200 && resp.StatusCode < 300
+		err = printObject(result, func() {
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			signature := "valid"
+			if badSignature {
+				signature = "deliberately invalid (--bad-signature)"
+			}
+			timestamp := req.Header.Get("X-Sapliy-Timestamp")
+			if staleTimestamp {
+				timestamp += fmt.Sprintf(" (deliberately %s old, --stale-timestamp)", staleTimestampAge)
+			}
+			fmt.Fprintf(stdout, "Signature:  %s\n", signature)
+			fmt.Fprintf(stdout, "Timestamp:  %s\n", timestamp)
+			fmt.Fprintf(stdout, "Response:   %d %s in %dms\n", resp.StatusCode, http.StatusText(resp.StatusCode), result.LatencyMS)
+			if len(respBody) > 0 {
+				fmt.Fprintln(stdout, strings.Repeat("─", 60))
+				fmt.Fprintln(stdout, truncate(string(respBody), 2000))
+			}
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			switch {
+			case broken && !accepted:
+				fmt.Fprintln(stdout, "✅ Receiver rejected the broken delivery, as it should.")
+			case !broken && accepted:
+				fmt.Fprintln(stdout, "✅ Receiver accepted the delivery.")
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case broken && accepted:
+			e := newCLIError(kindGeneral, "receiver accepted a delivery it should reject (%d %s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+			e.Hint = "verify the signature and timestamp before processing a webhook"
+			return e
+		case !broken && !accepted:
+			return newCLIError(kindGeneral, "receiver responded %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		return nil
+	},
+}
+
+// readEventData reads --data: inline JSON, @file, or @- for stdin.
+func readEventData(arg string) (json.RawMessage, error) {
+	data := []byte(arg)
+	if path, ok := strings.CutPrefix(arg, "@"); ok {
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, validationError("read --data: %v", err)
+		}
+	}
+	if !json.Valid(data) {
+		return nil, validationError("--data is not valid JSON")
+	}
+	return data, nil
+}
+
+// newEventID returns a random event ID in the API's format.
+func newEventID() string {
+	var b [12]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "evt_" + hex.EncodeToString(b[:])
+}
+
+// newThrowawaySecret returns a random signing secret that no receiver
+// knows.
+func newThrowawaySecret() string {
+	var b [32]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return "whsec_" + hex.EncodeToString(b[:])
+}
+
+func init() {
+	webhooksCmd.AddCommand(webhooksSendCmd)
+
+	webhooksSendCmd.Flags().StringP("type", "t", "", "Event type, e.g. payment.succeeded")
+	webhooksSendCmd.Flags().StringP("data", "d", "{}", "Event data: JSON, @file.json, or @- for stdin")
+	webhooksSendCmd.Flags().String("id", "", "Event ID (default: generated)")
+	webhooksSendCmd.Flags().StringArray("secret", nil, "Signing secret; repeat to sign with several, as during a rotation (default: webhook_secret)")
+	webhooksSendCmd.Flags().Bool("bad-signature", false, "Send a well-formed but invalid signature")
+	webhooksSendCmd.Flags().Bool("stale-timestamp", false, fmt.Sprintf("Send a timestamp %s in the past", staleTimestampAge))
+	webhooksSendCmd.MarkFlagRequired("type")
+}
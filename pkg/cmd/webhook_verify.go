@@ -0,0 +1,511 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// verifyCheck is one step of 'webhooks verify'.
+type verifyCheck struct {
+	Check  string `json:"check"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// verifyReport is the outcome of 'webhooks verify'. Reason explains a
+// failure in one sentence.
+type verifyReport struct {
+	Valid             bool          `json:"valid"`
+	Reason            string        `json:"reason,omitempty"`
+	ExpectedSignature string        `json:"expected_signature"`
+	Checks            []verifyCheck `json:"checks"`
+}
+
+// namedSecret is a signing secret and where it came from.
+type namedSecret struct {
+	Source string
+	Value  string
+}
+
+var webhooksVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the signature of a captured webhook delivery",
+	Long: `Verify a captured webhook delivery offline and explain why it fails.
+
+--signature is the X-Sapliy-Signature header as received: t=<timestamp>,v1=<hex>
+signs the timestamp together with the body. A bare legacy signature of the
+body alone is rejected unless --allow-legacy-signatures is set.
+
+When the signature does not match, common causes are tried: a secret with
+whitespace or a missing whsec_ prefix, a trailing newline or byte order mark
+added when the body was saved, changed line endings, re-indented or
+re-serialized JSON, and bodies stored as base64, as a JSON string or as
+Latin-1, as well as a sender using the wrong signing scheme. The timestamp
+is checked against --tolerance as of --received-at.`,
+	Example: `  sapliy webhooks verify --payload body.json --signature 't=1705314600,v1=5257a8...'
+  pbpaste | sapliy webhooks verify --payload - --signature 't=1705314600,v1=5257a8...' --secret whsec_...
+  sapliy webhooks verify --payload body.json --signature 't=1705314600,v1=5257a8...' --received-at 2024-01-15T10:31:00Z
+  sapliy webhooks verify --payload body.json --signature 5257a8... --timestamp 1705314600 --allow-legacy-signatures`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payloadPath, _ := cmd.Flags().GetString("payload")
+		signature, _ := cmd.Flags().GetString("signature")
+		timestamp, _ := cmd.Flags().GetString("timestamp")
+		tolerance := viper.GetDuration("webhook_tolerance")
+		if cmd.Flags().Changed("tolerance") {
+			tolerance, _ = cmd.Flags().GetDuration("tolerance")
+		}
+		allowLegacy := viper.GetBool("webhook_legacy_signatures")
+		if cmd.Flags().Changed("allow-legacy-signatures") {
+			allowLegacy, _ = cmd.Flags().GetBool("allow-legacy-signatures")
+		}
+		receivedAt, _ := cmd.Flags().GetString("received-at")
+
+		payload, err := readPayload(payloadPath)
+		if err != nil {
+			return err
+		}
+		now, err := parseTimeBound(receivedAt, time.Now())
+		if err != nil {
+			return validationError("--received-at: %v", err)
+		}
+		if now.IsZero() {
+			now = time.Now()
+		}
+		secrets, err := verifySecrets(cmd)
+		if err != nil {
+			return err
+		}
+
+		report := diagnoseDelivery(payload, signature, timestamp, secrets, tolerance, allowLegacy, now)
+
+		err = printObject(report, func() {
+			green := color.New(color.FgGreen, color.Bold)
+			red := color.New(color.FgRed, color.Bold)
+
+			fmt.Fprintln(stdout, "🔏 Webhook Signature Check")
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			fmt.Fprintf(stdout, "Payload:    %s (%d bytes)\n", payloadPath, len(payload))
+			fmt.Fprintf(stdout, "Signature:  %s\n", signature)
+			if report.ExpectedSignature != "" {
+				fmt.Fprintf(stdout, "Expected:   %s (secret from %s)\n", report.ExpectedSignature, secrets[0].Source)
+			}
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			for _, c := range report.Checks {
+				mark := green.Sprint("✓")
+				if !c.OK {
+					mark = red.Sprint("✗")
+				}
+				fmt.Fprintf(stdout, "%s %-18s %s\n", mark, c.Check, c.Detail)
+			}
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			if report.Valid {
+				fmt.Fprintf(stdout, "Result: %s\n", green.Sprint("VALID"))
+			} else {
+				fmt.Fprintf(stdout, "Result: %s\n", red.Sprint("INVALID"))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if !report.Valid {
+			return newCLIError(kindGeneral, "webhook verification failed: %s", report.Reason)
+		}
+		return nil
+	},
+}
+
+// readPayload reads a captured body from a file, or from stdin for "-".
+// The bytes are used exactly as stored.
+func readPayload(path string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, validationError("read payload: %v", err)
+	}
+	return data, nil
+}
+
+// verifySecrets returns the --secret values, or the configured
+// webhook_secret.
+func verifySecrets(cmd *cobra.Command) ([]namedSecret, error) {
+	values, _ := cmd.Flags().GetStringArray("secret")
+	var secrets []namedSecret
+	for i, v := range values {
+		registerSecret(v)
+		source := "--secret"
+		if len(values) > 1 {
+			source = fmt.Sprintf("--secret #%d", i+1)
+		}
+		secrets = append(secrets, namedSecret{Source: source, Value: v})
+	}
+	if len(secrets) == 0 {
+		configured := webhookSecrets()
+		for i, v := range configured {
+			source := settingOrigin("webhook_secret")
+			if len(configured) > 1 {
+				source = fmt.Sprintf("%s #%d", source, i+1)
+			}
+			secrets = append(secrets, namedSecret{Source: source, Value: v})
+		}
+	}
+	if len(secrets) == 0 {
+		e := newCLIError(kindValidation, "no signing secret")
+		e.Hint = "pass --secret or run 'sapliy config set webhook_secret <secret>'"
+		return nil, e
+	}
+	return secrets, nil
+}
+
+// diagnoseDelivery verifies a delivery and, when it fails, looks for the
+// change to the body, secret or signing scheme that explains it.
+func diagnoseDelivery(payload []byte, signature, timestamp string, secrets []namedSecret, tolerance time.Duration, allowLegacy bool, now time.Time) verifyReport {
+	var r verifyReport
+	var reasons []string
+	check := func(name string, ok bool, detail string) {
+		r.Checks = append(r.Checks, verifyCheck{Check: name, OK: ok, Detail: detail})
+	}
+	fail := func(name, reason string) {
+		check(name, false, reason)
+		reasons = append(reasons, reason)
+	}
+
+	// The signed content depends on the scheme: "<t>.<body>" for v1, the
+	// body alone for legacy signatures.
+	var (
+		values   []string
+		signedAt = timestamp
+		content  = func(b []byte) []byte { return b }
+		schemes  []schemeVariant
+	)
+	if isVersionedSignature(signature) {
+		h, err := parseSignatureHeader(signature)
+		if err != nil {
+			fail("Signature format", err.Error())
+		} else {
+			values, signedAt = h.Signatures, h.Timestamp
+			content = func(b []byte) []byte { return signedPayload(h.Timestamp, b) }
+			schemes = append(schemes, schemeVariant{"signing the body alone",
+				"the sender uses the legacy scheme; v1 signs the timestamp, a dot and the body",
+				func(b []byte) []byte { return b }})
+			if timestamp != "" && timestamp != h.Timestamp {
+				fail("Timestamp header", fmt.Sprintf("--timestamp %s differs from the signed t=%s", timestamp, h.Timestamp))
+				schemes = append(schemes, schemeVariant{"signing the --timestamp value instead of t=",
+					"the sender signed a different timestamp than the one in the signature header",
+					func(b []byte) []byte { return signedPayload(timestamp, b) }})
+			}
+			r.ExpectedSignature = signatureHeader{Timestamp: h.Timestamp,
+				Signatures: []string{computeSignature(secrets[0].Value, content(payload))}}.String()
+		}
+	} else {
+		values = []string{signature}
+		if allowLegacy {
+			check("Signature scheme", true, "legacy signature of the body alone; the timestamp is not signed")
+		} else {
+			fail("Signature scheme", errLegacySignature.Error())
+		}
+		if timestamp != "" {
+			schemes = append(schemes, schemeVariant{fmt.Sprintf("signing %q", timestamp+".<body>"),
+				"the signature covers the timestamp; send it as t=<timestamp>,v1=<signature>",
+				func(b []byte) []byte { return signedPayload(timestamp, b) }})
+		}
+		r.ExpectedSignature = computeSignature(secrets[0].Value, payload)
+	}
+
+	var macs [][]byte
+	for _, v := range values {
+		mac, note, err := decodeSignature(v)
+		switch {
+		case err != nil:
+			fail("Signature format", err.Error())
+		case strings.Contains(note, "base64"):
+			fail("Signature format", note)
+			macs = append(macs, mac)
+		default:
+			detail := "hex-encoded HMAC-SHA256"
+			if len(values) > 1 {
+				detail = fmt.Sprintf("%d hex-encoded HMAC-SHA256 signatures", len(values))
+			}
+			if note != "" {
+				detail += " (" + note + ")"
+			}
+			if len(macs) == 0 {
+				check("Signature format", true, detail)
+			}
+			macs = append(macs, mac)
+		}
+	}
+
+	matched := false
+	if len(macs) > 0 {
+		if s, ok := matchingSecret(macs, content(payload), secrets); ok {
+			matched = true
+			check("Signature", true, "matches the payload with the secret from "+s.Source)
+		} else {
+			check("Signature", false, "does not match the payload with the secret from "+secretSources(secrets))
+			name, reason := explainMismatch(macs, payload, content, schemes, secrets)
+			fail(name, reason)
+		}
+	}
+
+	if signedAt == "" {
+		check("Timestamp", true, "not given; pass --timestamp to check freshness")
+	} else if ts, err := parseSignatureTimestamp(signedAt); err != nil {
+		fail("Timestamp", err.Error())
+	} else if err := checkSignatureTimestamp(ts, now, tolerance); err != nil {
+		fail("Timestamp", err.Error())
+	} else {
+		check("Timestamp", true, fmt.Sprintf("%s, within the %s tolerance", ts.UTC().Format(time.RFC3339), tolerance))
+	}
+
+	r.Valid = matched && len(reasons) == 0
+	r.Reason = strings.Join(reasons, "; ")
+	return r
+}
+
+// schemeVariant is a signing scheme a sender may have used by mistake.
+type schemeVariant struct {
+	Change      string
+	Explanation string
+	content     func([]byte) []byte
+}
+
+// matchingSecret returns the secret under which any of macs signs content.
+func matchingSecret(macs [][]byte, content []byte, secrets []namedSecret) (namedSecret, bool) {
+	for _, s := range secrets {
+		want := signatureMAC(s.Value, content)
+		for _, mac := range macs {
+			if hmac.Equal(mac, want) {
+				return s, true
+			}
+		}
+	}
+	return namedSecret{}, false
+}
+
+func secretSources(secrets []namedSecret) string {
+	sources := make([]string, len(secrets))
+	for i, s := range secrets {
+		sources[i] = s.Source
+	}
+	return strings.Join(sources, ", ")
+}
+
+// explainMismatch tries common changes to the secret, then other signing
+// schemes, then changes to the body, and returns the check that found the
+// cause and the explanation.
+func explainMismatch(macs [][]byte, payload []byte, content func([]byte) []byte, schemes []schemeVariant, secrets []namedSecret) (check, reason string) {
+	for _, s := range secrets {
+		for _, v := range secretVariants {
+			if secret, ok := v.apply(s.Value); ok {
+				if _, ok := matchingSecret(macs, content(payload), []namedSecret{{Value: secret}}); ok {
+					return "Secret", fmt.Sprintf("matches after %s (secret from %s); %s", v.Change, s.Source, v.Explanation)
+				}
+			}
+		}
+	}
+	for _, v := range schemes {
+		if s, ok := matchingSecret(macs, v.content(payload), secrets); ok {
+			return "Scheme", withSecretSource(fmt.Sprintf("matches after %s; %s", v.Change, v.Explanation), s, secrets)
+		}
+	}
+	for _, v := range payloadVariants {
+		body, ok := v.apply(payload)
+		if !ok {
+			continue
+		}
+		if s, ok := matchingSecret(macs, content(body), secrets); ok {
+			return "Payload", withSecretSource(fmt.Sprintf("matches after %s; %s", v.Change, v.Explanation), s, secrets)
+		}
+	}
+	return "Diagnosis", "no common change to the body, secret or scheme explains it; the delivery was most likely signed " +
+		"with a different secret (rotated, another endpoint, or the other mode) or the body was modified"
+}
+
+// withSecretSource names the matching secret when there was a choice.
+func withSecretSource(reason string, s namedSecret, secrets []namedSecret) string {
+	if len(secrets) > 1 {
+		reason += " (secret from " + s.Source + ")"
+	}
+	return reason
+}
+
+// decodeSignature decodes a signature as sent in the header: hex, optionally
+// prefixed with "sha256=" or "v1=". It also recognizes base64, which is not
+// what Sapliy sends; note says so.
+func decodeSignature(signature string) (mac []byte, note string, err error) {
+	s := strings.TrimSpace(signature)
+	for _, prefix := range []string{"sha256=", "v1="} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			s = rest
+			note = fmt.Sprintf("%q prefix ignored", prefix)
+			break
+		}
+	}
+	if b, err := hex.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, note, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil && len(b) == sha256.Size {
+			return b, "signature is base64-encoded, but Sapliy sends hex; a receiver comparing hex strings rejects it", nil
+		}
+	}
+	return nil, "", fmt.Errorf("signature is not a hex-encoded SHA-256 HMAC (got %d characters, expected 64 hex digits)", len(s))
+}
+
+// payloadVariant is a way a captured body commonly differs from the bytes
+// that were signed.
+type payloadVariant struct {
+	Change      string
+	Explanation string
+	apply       func([]byte) ([]byte, bool)
+}
+
+// payloadVariants are tried in order when a signature does not match, to
+// explain why.
+var payloadVariants = []payloadVariant{
+	{"removing the trailing newline", "a newline was added when the body was saved; sign and verify the raw bytes",
+		func(b []byte) ([]byte, bool) {
+			t := bytes.TrimRight(b, "\r\n")
+			return t, len(t) != len(b)
+		}},
+	{"adding a trailing newline", "the trailing newline of the delivered body was stripped",
+		func(b []byte) ([]byte, bool) { return append(bytes.Clone(b), '\n'), true }},
+	{"converting CRLF line endings to LF", "line endings were converted to CRLF after delivery",
+		func(b []byte) ([]byte, bool) {
+			return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")), bytes.Contains(b, []byte("\r\n"))
+		}},
+	{"converting LF line endings to CRLF", "line endings were converted from CRLF to LF after delivery",
+		func(b []byte) ([]byte, bool) {
+			if bytes.Contains(b, []byte("\r\n")) || !bytes.Contains(b, []byte("\n")) {
+				return nil, false
+			}
+			return bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n")), true
+		}},
+	{"removing the UTF-8 byte order mark", "an editor added a byte order mark when the body was saved",
+		func(b []byte) ([]byte, bool) {
+			t := bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+			return t, len(t) != len(b)
+		}},
+	{"compacting the JSON", "the JSON was pretty-printed or its whitespace changed; verify the raw body before parsing it",
+		func(b []byte) ([]byte, bool) {
+			var buf bytes.Buffer
+			if json.Compact(&buf, b) != nil || bytes.Equal(buf.Bytes(), b) {
+				return nil, false
+			}
+			return buf.Bytes(), true
+		}},
+	{"indenting the JSON with two spaces", "the JSON was compacted or re-indented after delivery",
+		func(b []byte) ([]byte, bool) {
+			var buf bytes.Buffer
+			if json.Indent(&buf, b, "", "  ") != nil || bytes.Equal(buf.Bytes(), b) {
+				return nil, false
+			}
+			return buf.Bytes(), true
+		}},
+	{`unescaping \u003c, \u003e and \u0026`, "the body was re-serialized by a JSON encoder that escapes HTML characters; verify the raw body",
+		func(b []byte) ([]byte, bool) {
+			r := strings.NewReplacer(`\u003c`, "<", `\u003e`, ">", `\u0026`, "&")
+			t := []byte(r.Replace(string(b)))
+			return t, !bytes.Equal(t, b)
+		}},
+	{`escaping <, > and & as \u003c, \u003e and \u0026`, "the body was re-serialized without HTML escaping; verify the raw body",
+		func(b []byte) ([]byte, bool) {
+			r := strings.NewReplacer("<", `\u003c`, ">", `\u003e`, "&", `\u0026`)
+			t := []byte(r.Replace(string(b)))
+			return t, !bytes.Equal(t, b)
+		}},
+	{"decoding it as a JSON string", "the body was stored as an escaped JSON string, not as raw bytes",
+		func(b []byte) ([]byte, bool) {
+			var s string
+			if json.Unmarshal(bytes.TrimSpace(b), &s) != nil {
+				return nil, false
+			}
+			return []byte(s), true
+		}},
+	{"decoding it from base64", "the body was stored base64-encoded",
+		func(b []byte) ([]byte, bool) {
+			t, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+			return t, err == nil
+		}},
+	{"re-encoding it from Latin-1 to UTF-8", "the body was saved as Latin-1; it is signed as UTF-8",
+		func(b []byte) ([]byte, bool) {
+			if utf8.Valid(b) {
+				return nil, false
+			}
+			r := make([]rune, len(b))
+			for i, c := range b {
+				r[i] = rune(c)
+			}
+			return []byte(string(r)), true
+		}},
+	{"re-encoding it from UTF-8 to Latin-1", "the receiver decoded the body as Latin-1 and re-encoded it",
+		func(b []byte) ([]byte, bool) {
+			if !utf8.Valid(b) {
+				return nil, false
+			}
+			out := make([]byte, 0, len(b))
+			changed := false
+			for _, r := range string(b) {
+				if r > 0xff {
+					return nil, false
+				}
+				changed = changed || r >= 0x80
+				out = append(out, byte(r))
+			}
+			return out, changed
+		}},
+}
+
+// secretVariant is a way a secret is commonly mangled when copied.
+type secretVariant struct {
+	Change      string
+	Explanation string
+	apply       func(string) (string, bool)
+}
+
+var secretVariants = []secretVariant{
+	{"trimming whitespace", "the secret has surrounding whitespace or a newline, e.g. from a copied file",
+		func(s string) (string, bool) {
+			t := strings.TrimSpace(s)
+			return t, t != s
+		}},
+	{"removing the whsec_ prefix", "the secret is used without its whsec_ prefix by the sender",
+		func(s string) (string, bool) { return strings.CutPrefix(s, "whsec_") }},
+	{"adding the whsec_ prefix", "the whsec_ prefix is part of the secret and must be kept",
+		func(s string) (string, bool) { return "whsec_" + s, !strings.HasPrefix(s, "whsec_") }},
+}
+
+func init() {
+	webhooksCmd.AddCommand(webhooksVerifyCmd)
+
+	webhooksVerifyCmd.Flags().String("payload", "", "File with the captured request body, or - for stdin")
+	webhooksVerifyCmd.Flags().String("signature", "", "Value of the X-Sapliy-Signature header")
+	webhooksVerifyCmd.Flags().String("timestamp", "", "Value of the X-Sapliy-Timestamp header (Unix seconds or RFC 3339); taken from t= when the signature has one")
+	webhooksVerifyCmd.Flags().StringArray("secret", nil, "Signing secret to verify with; repeat to try several (default: webhook_secret)")
+	webhooksVerifyCmd.Flags().Duration("tolerance", defaultSignatureTolerance, "Maximum age of the timestamp (overrides webhook_tolerance)")
+	webhooksVerifyCmd.Flags().Bool("allow-legacy-signatures", false, "Accept legacy signatures of the body alone (overrides webhook_legacy_signatures)")
+	webhooksVerifyCmd.Flags().String("received-at", "", "When the delivery was received, for the timestamp check (default: now)")
+	webhooksVerifyCmd.MarkFlagRequired("payload")
+	webhooksVerifyCmd.MarkFlagRequired("signature")
+}
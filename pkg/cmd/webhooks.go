@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/sapliy/fintech-sdk-go"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,61 +22,114 @@ var webhooksCmd = &cobra.Command{
 List past webhook deliveries and replay failed or missed webhooks.`,
 }
 
+// replayResult reports the outcome of replaying one event.
+type replayResult struct {
+	EventID        string `json:"event_id"`
+	Type           string `json:"type,omitempty"`
+	ZoneID         string `json:"zone_id"`
+	Status         string `json:"status"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
 var webhooksListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List recent webhook events",
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set. Use 'sapliy auth login'.")
-			os.Exit(1)
+	Long: `List webhook events, newest first.
+
+One page is fetched by default; the cursor to the next page is printed at
+the end. --all fetches every page, printing each as it arrives, so long
+ranges can be exported without holding them in memory.`,
+	Example: `  sapliy webhooks list --page-size 50
+  sapliy webhooks list --starting-after evt_123
+  sapliy webhooks list --all --since 30d -o json > events.json
+  sapliy webhooks list --all --since 2024-01-01 --until 2024-02-01 -o csv
+  sapliy webhooks list --all --status failed --type 'payment.*' --min-attempts 3`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
 		}
 
-		zone := viper.GetString("current_zone")
-		if zoneID != "" {
-			zone = zoneID
+		zone, err := webhookZone()
+		if err != nil {
+			return err
 		}
 
-		if zone == "" {
-			fmt.Println("Error: Zone ID is required. Use --zone or set in config.")
-			return
+		params := eventListParams{ZoneID: zone}
+		params.PageSize, _ = cmd.Flags().GetInt("page-size")
+		if cmd.Flags().Changed("limit") {
+			params.PageSize, _ = cmd.Flags().GetInt("limit")
 		}
+		if params.PageSize < 1 || params.PageSize > maxEventPageSize {
+			return validationError("--page-size must be between 1 and %d", maxEventPageSize)
+		}
+		params.StartingAfter, _ = cmd.Flags().GetString("starting-after")
+		params.EndingBefore, _ = cmd.Flags().GetString("ending-before")
 
-		fmt.Printf("📋 Fetching webhook events (zone: %s)...\n", zone)
-		fmt.Println(strings.Repeat("─", 80))
+		now := time.Now()
+		since, _ := cmd.Flags().GetString("since")
+		until, _ := cmd.Flags().GetString("until")
+		if params.Since, err = parseTimeBound(since, now); err != nil {
+			return validationError("--since: %v", err)
+		}
+		if params.Until, err = parseTimeBound(until, now); err != nil {
+			return validationError("--until: %v", err)
+		}
+		all, _ := cmd.Flags().GetBool("all")
+
+		params.Filter.Status, _ = cmd.Flags().GetString("status")
+		params.Filter.Type, _ = cmd.Flags().GetString("type")
+		params.Filter.Endpoint, _ = cmd.Flags().GetString("endpoint")
+		params.Filter.MinAttempts, _ = cmd.Flags().GetInt("min-attempts")
+		if params.Filter.Status != "" && !slices.Contains(eventStatuses, params.Filter.Status) {
+			return validationError("--status must be one of %s", strings.Join(eventStatuses, ", "))
+		}
 
-		client := fintech.NewClient(apiKey, fintech.WithBaseURL(viper.GetString("api_url")))
+		w, err := newListWriter([]column[webhookEvent]{
+			{"EVENT ID", func(e webhookEvent) string { return e.ID }},
+			{"TYPE", func(e webhookEvent) string { return e.Type }},
+			{"STATUS", func(e webhookEvent) string { return e.Status }},
+			{"ATTEMPTS", func(e webhookEvent) string { return strconv.Itoa(e.Attempts) }},
+			{"ENDPOINT", func(e webhookEvent) string { return e.Endpoint }},
+			{"CREATED AT", func(e webhookEvent) string { return e.CreatedAt.Format(time.RFC3339) }},
+			{"DATA", func(e webhookEvent) string {
+				data, _ := json.Marshal(e.Data)
+				return string(data)
+			}},
+		}, func() {
+			fmt.Fprintf(stdout, "%-24s %-25s %-10s %-8s %-32s %-15s %s\n", "EVENT ID", "TYPE", "STATUS", "ATTEMPTS", "ENDPOINT", "CREATED AT", "DATA")
+			fmt.Fprintln(stdout, strings.Repeat("─", 140))
+		}, func(evt webhookEvent) {
+			timestamp := evt.CreatedAt.Format("Jan 02 15:04")
+			data, _ := json.Marshal(evt.Data)
+			dataStr := truncate(string(data), 30)
 
-		limit, _ := cmd.Flags().GetInt("limit")
+			fmt.Fprintf(stdout, "%-24s %-25s %-10s %-8d %-32s %-15s %s\n",
+				evt.ID, evt.Type, valueOrDash(evt.Status), evt.Attempts, truncate(valueOrDash(evt.Endpoint), 32), timestamp, dataStr)
+		})
+		if err != nil {
+			return err
+		}
 
-		// In a real implementation, we'd need a GetPastEvents method in the SDK
-		// Let's assume we use the do method directly if the SDK doesn't have it yet
-		// But for now, I'll use a placeholder that describes the real API call
-		// actually, I'll add GetPastEvents to the SDK as well
+		fmt.Fprintf(progress(), "📋 Fetching webhook events (zone: %s)...\n", zone)
+		fmt.Fprintln(progress(), strings.Repeat("─", 140))
 
-		events, err := client.GetPastEvents(context.Background(), zone, limit, 0)
+		next, err := listEvents(context.Background(), params, all, w.write)
 		if err != nil {
-			fmt.Printf("Error: Failed to fetch events: %v\n", err)
-			return
+			return fmt.Errorf("fetch events: %w", err)
 		}
-
-		if len(events) == 0 {
-			fmt.Println("No webhook events found.")
-			return
+		if err := w.close("No webhook events found."); err != nil {
+			return err
 		}
 
-		// Header
-		fmt.Printf("%-24s %-25s %-15s %-15s\n", "EVENT ID", "TYPE", "CREATED AT", "DATA")
-		fmt.Println(strings.Repeat("─", 80))
-
-		for _, evt := range events {
-			timestamp := evt.CreatedAt.Format("Jan 02 15:04")
-			data, _ := json.Marshal(evt.Data)
-			dataStr := truncate(string(data), 30)
-
-			fmt.Printf("%-24s %-25s %-15s %s\n",
-				evt.ID, evt.Type, timestamp, dataStr)
+		if next != "" {
+			flag := "--starting-after"
+			if params.EndingBefore != "" {
+				flag = "--ending-before"
+			}
+			fmt.Fprintf(progress(), "\nMore events available: use %s %s, or --all\n", flag, next)
 		}
+		return nil
 	},
 }
 
@@ -82,149 +137,324 @@ var webhooksReplayCmd = &cobra.Command{
 	Use:   "replay [event_id]",
 	Short: "Replay a webhook event",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set.")
-			os.Exit(1)
-		}
-
-		zone := viper.GetString("current_zone")
-		if zoneID != "" {
-			zone = zoneID
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
 		}
 
-		if zone == "" {
-			fmt.Println("Error: Zone ID is required. Use --zone or set in config.")
-			return
+		zone, err := webhookZone()
+		if err != nil {
+			return err
 		}
 
 		eventID := args[0]
 		force, _ := cmd.Flags().GetBool("force")
 
-		fmt.Printf("🔄 Replaying webhook event: %s in zone: %s\n", eventID, zone)
+		fmt.Fprintf(progress(), "🔄 Replaying webhook event: %s in zone: %s\n", eventID, zone)
 
 		if !force {
-			fmt.Print("Are you sure you want to replay this webhook? [y/N]: ")
+			fmt.Fprint(progress(), "Are you sure you want to replay this webhook? [y/N]: ")
 			var response string
 			fmt.Scanln(&response)
 			if strings.ToLower(response) != "y" {
-				fmt.Println("Cancelled.")
-				return
+				fmt.Fprintln(progress(), "Cancelled.")
+				return nil
 			}
 		}
 
-		client := fintech.NewClient(apiKey, fintech.WithBaseURL(viper.GetString("api_url")))
-		err := client.ReplayEvent(context.Background(), eventID, zone)
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
+		key := commandIdempotencyKey(cmd)
+		err = client.ReplayEvent(withIdempotencyKey(context.Background(), key), eventID, zone)
 		if err != nil {
-			fmt.Printf("❌ Failed to replay event: %v\n", err)
-			return
+			return withIdempotencyKeyHint(fmt.Errorf("replay event: %w", err), key)
 		}
 
-		fmt.Println("✅ Webhook replay triggered!")
+		result := replayResult{EventID: eventID, ZoneID: zone, Status: replayReplayed, IdempotencyKey: key}
+		return printObject(result, func() {
+			fmt.Fprintln(stdout, "✅ Webhook replay triggered!")
+		})
 	},
 }
 
 var webhooksReplayFailedCmd = &cobra.Command{
 	Use:   "replay-failed",
 	Short: "Replay all failed webhook events",
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set.")
-			os.Exit(1)
+	Long: `Replay every failed webhook delivery in the zone within the --since window.
+
+Replays run in parallel (--concurrency) and are rate limited (--rate) so a
+backlog after an endpoint outage does not overwhelm the API or the endpoint.
+Each outcome is reported as it finishes, followed by a summary. The command
+exits non-zero if any replay failed; every failed replay lists the
+Idempotency-Key to retry it with 'sapliy webhooks replay'.`,
+	Example: `  sapliy webhooks replay-failed --since 6h --dry-run
+  sapliy webhooks replay-failed --since 2024-01-15T10:00:00Z --endpoint hooks.example.com
+  sapliy webhooks replay-failed --concurrency 8 --rate 20 -o json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
+		}
+
+		zone, err := webhookZone()
+		if err != nil {
+			return err
 		}
 
-		zone := viper.GetString("current_zone")
 		since, _ := cmd.Flags().GetString("since")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		var opts replayOptions
+		opts.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+		opts.Rate, _ = cmd.Flags().GetFloat64("rate")
+		if opts.Concurrency < 1 {
+			return validationError("--concurrency must be at least 1")
+		}
+		if opts.Rate < 0 {
+			return validationError("--rate must not be negative")
+		}
 
-		fmt.Printf("🔍 Finding failed webhooks (zone: %s, since: %s)...\n", zone, since)
+		params := eventListParams{ZoneID: zone, PageSize: maxEventPageSize}
+		if params.Since, err = parseTimeBound(since, time.Now()); err != nil {
+			return validationError("--since: %v", err)
+		}
+		params.Filter.Status = "failed"
+		params.Filter.Type, _ = cmd.Flags().GetString("type")
+		params.Filter.Endpoint, _ = cmd.Flags().GetString("endpoint")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Fprintf(progress(), "🔍 Finding failed webhooks (zone: %s, since: %s)...\n", zone, since)
+
+		var failed []webhookEvent
+		seen := map[string]bool{}
+		_, err = listEvents(ctx, params, true, func(events []webhookEvent) error {
+			for _, e := range events {
+				if !seen[e.ID] {
+					seen[e.ID] = true
+					failed = append(failed, e)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("fetch failed events: %w", err)
+		}
 
-		// Demo data
-		failedEvents := []string{"we_def456", "we_xyz999"}
+		columns := []column[replayResult]{
+			{"EVENT ID", func(r replayResult) string { return r.EventID }},
+			{"TYPE", func(r replayResult) string { return r.Type }},
+			{"ZONE", func(r replayResult) string { return r.ZoneID }},
+			{"STATUS", func(r replayResult) string { return r.Status }},
+			{"IDEMPOTENCY KEY", func(r replayResult) string { return r.IdempotencyKey }},
+			{"ERROR", func(r replayResult) string { return r.Error }},
+		}
 
-		if len(failedEvents) == 0 {
-			fmt.Println("✅ No failed webhooks found.")
-			return
+		if len(failed) == 0 || dryRun {
+			results := make([]replayResult, len(failed))
+			for i, e := range failed {
+				results[i] = replayResult{EventID: e.ID, Type: e.Type, ZoneID: zone, Status: replayWouldReplay}
+			}
+			return printList(results, columns, func() {
+				if len(failed) == 0 {
+					fmt.Fprintln(stdout, "✅ No failed webhooks found.")
+					return
+				}
+
+				fmt.Fprintf(stdout, "Found %d failed webhook(s)\n", len(failed))
+				fmt.Fprintln(stdout, "\n🏃 Dry run - would replay:")
+				for _, e := range failed {
+					fmt.Fprintf(stdout, "   - %-24s %-25s %d attempt(s)  %s\n",
+						e.ID, e.Type, e.Attempts, valueOrDash(e.Endpoint))
+				}
+			})
 		}
 
-		fmt.Printf("Found %d failed webhook(s)\n", len(failedEvents))
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
 
-		if dryRun {
-			fmt.Println("\n🏃 Dry run - would replay:")
-			for _, evt := range failedEvents {
-				fmt.Printf("   - %s\n", evt)
+		fmt.Fprintf(progress(), "Found %d failed webhook(s)\n", len(failed))
+		fmt.Fprintf(progress(), "\nReplaying (concurrency %d, %s)...\n", opts.Concurrency, replayRateLabel(opts.Rate))
+
+		start := time.Now()
+		var results []replayResult
+		counts := map[string]int{}
+		replayEvents(ctx, client, zone, failed, opts, func(r replayResult) {
+			results = append(results, r)
+			counts[r.Status]++
+			switch r.Status {
+			case replayReplayed:
+				fmt.Fprintf(progress(), "   ✅ %s → Replayed\n", r.EventID)
+			case replayFailed:
+				fmt.Fprintf(progress(), "   ❌ %s → %s (Idempotency-Key: %s)\n", r.EventID, r.Error, r.IdempotencyKey)
+			case replaySkipped:
+				fmt.Fprintf(progress(), "   ⏭  %s → Skipped (interrupted)\n", r.EventID)
 			}
-			return
+		})
+
+		summary := fmt.Sprintf("Completed in %s: %d succeeded, %d failed",
+			time.Since(start).Round(time.Millisecond), counts[replayReplayed], counts[replayFailed])
+		if counts[replaySkipped] > 0 {
+			summary += fmt.Sprintf(", %d skipped", counts[replaySkipped])
 		}
+		fmt.Fprintln(progress(), strings.Repeat("─", 40))
+		fmt.Fprintln(progress(), summary)
 
-		fmt.Println("\nReplaying...")
-		for _, evt := range failedEvents {
-			fmt.Printf("   ✅ %s → Replayed\n", evt)
+		if err := printList(results, columns, func() {}); err != nil {
+			return err
 		}
 
-		fmt.Println(strings.Repeat("─", 40))
-		fmt.Printf("Completed: %d succeeded\n", len(failedEvents))
+		switch {
+		case counts[replayFailed] > 0:
+			e := newCLIError(kindGeneral, "%d of %d replays failed", counts[replayFailed], len(failed))
+			e.Hint = "retry an event with 'sapliy webhooks replay <event_id> --idempotency-key <key>'"
+			return e
+		case counts[replaySkipped] > 0:
+			return newCLIError(kindGeneral, "interrupted: %d replays skipped", counts[replaySkipped])
+		}
+		return nil
 	},
 }
 
+// replayRateLabel describes a --rate value for progress output.
+func replayRateLabel(rate float64) string {
+	if rate <= 0 {
+		return "no rate limit"
+	}
+	return fmt.Sprintf("max %s/s", strconv.FormatFloat(rate, 'f', -1, 64))
+}
+
 var webhooksInspectCmd = &cobra.Command{
 	Use:   "inspect [event_id]",
 	Short: "Inspect a webhook event in detail",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		if apiKey == "" {
-			fmt.Println("Error: API key not set.")
-			os.Exit(1)
+	Long: `Show an event, its payload and every delivery attempt: when it was made,
+the endpoint, the HTTP status, latency, an excerpt of the response and when
+the next retry is scheduled.
+
+--attempt N prints the exact request sent for that attempt, headers and
+body, so the failure can be reproduced against a local server.`,
+	Example: `  sapliy webhooks inspect evt_123
+  sapliy webhooks inspect evt_123 --attempt 2
+  sapliy webhooks inspect evt_123 --attempt 2 -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := requireAPIKey(); err != nil {
+			return err
+		}
+
+		zone, err := webhookZone()
+		if err != nil {
+			return err
 		}
 
 		eventID := args[0]
+		attemptNumber, _ := cmd.Flags().GetInt("attempt")
+
+		event, err := getEventDetail(context.Background(), zone, eventID)
+		if err != nil {
+			return fmt.Errorf("inspect event %s: %w", eventID, err)
+		}
+
+		if cmd.Flags().Changed("attempt") {
+			attempt, ok := event.attempt(attemptNumber)
+			if !ok {
+				e := newCLIError(kindNotFound, "event %s has no delivery attempt %d", eventID, attemptNumber)
+				e.Hint = fmt.Sprintf("it has %d attempt(s); run 'sapliy webhooks inspect %s' to list them", len(event.DeliveryAttempts), eventID)
+				return e
+			}
+			if attempt.Request == nil {
+				return notFoundError("the request for attempt %d of event %s was not recorded", attemptNumber, eventID)
+			}
+			return printObject(attempt, func() {
+				printAttemptRequest(event, attempt)
+			})
+		}
 
-		fmt.Printf("📦 Webhook Event: %s\n", eventID)
-		fmt.Println(strings.Repeat("─", 60))
-
-		// Demo data
-		event := map[string]interface{}{
-			"id":           eventID,
-			"type":         "payment.succeeded",
-			"status":       "succeeded",
-			"endpoint":     "https://example.com/webhook",
-			"createdAt":    "2024-01-15T10:30:00Z",
-			"deliveredAt":  "2024-01-15T10:30:01Z",
-			"attempts":     1,
-			"responseCode": 200,
-			"payload": map[string]interface{}{
-				"amount":   5000,
-				"currency": "USD",
-				"customer": "cus_abc123",
-			},
-		}
-
-		fmt.Printf("Type:        %s\n", event["type"])
-		fmt.Printf("Status:      %s\n", event["status"])
-		fmt.Printf("Endpoint:    %s\n", event["endpoint"])
-		fmt.Printf("Created:     %s\n", event["createdAt"])
-		fmt.Printf("Delivered:   %s\n", formatTimestamp(event["deliveredAt"].(string)))
-		fmt.Printf("Attempts:    %v\n", event["attempts"])
-		fmt.Printf("Response:    %v\n", event["responseCode"])
-
-		fmt.Println("\nPayload:")
-		prettyJSON, _ := json.MarshalIndent(event["payload"], "", "  ")
-		fmt.Println(string(prettyJSON))
+		return printObject(event, func() {
+			fmt.Fprintf(stdout, "📦 Webhook Event: %s\n", event.ID)
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+			fmt.Fprintf(stdout, "Type:        %s\n", event.Type)
+			fmt.Fprintf(stdout, "Status:      %s\n", valueOrDash(event.Status))
+			fmt.Fprintf(stdout, "Endpoint:    %s\n", valueOrDash(event.Endpoint))
+			fmt.Fprintf(stdout, "Created:     %s\n", event.CreatedAt.Format(time.RFC3339))
+			if event.MaxAttempts > 0 {
+				fmt.Fprintf(stdout, "Attempts:    %d of %d\n", event.Attempts, event.MaxAttempts)
+			} else {
+				fmt.Fprintf(stdout, "Attempts:    %d\n", event.Attempts)
+			}
+			if event.NextRetryAt != nil {
+				fmt.Fprintf(stdout, "Next retry:  %s\n", formatRetry(event.NextRetryAt, time.Now()))
+			}
+
+			fmt.Fprintln(stdout, "\nPayload:")
+			prettyJSON, _ := json.MarshalIndent(event.Data, "", "  ")
+			fmt.Fprintln(stdout, string(prettyJSON))
+
+			fmt.Fprintln(stdout, "\nDelivery Attempts:")
+			fmt.Fprintln(stdout, strings.Repeat("─", 60))
+			if len(event.DeliveryAttempts) == 0 {
+				fmt.Fprintln(stdout, "No delivery attempts yet.")
+				return
+			}
+			for _, a := range event.DeliveryAttempts {
+				retry := fmt.Sprintf("#%d", a.Number)
+				if event.MaxAttempts > 0 {
+					retry = fmt.Sprintf("#%d of %d", a.Number, event.MaxAttempts)
+				}
+				fmt.Fprintf(stdout, "%-8s %s  %s\n", retry, a.AttemptedAt.Local().Format("Jan 02 15:04:05"), a.Endpoint)
+				fmt.Fprintf(stdout, "   Status:     %s in %s\n", a.outcome(), a.latency())
+				if a.ResponseBody != "" {
+					fmt.Fprintf(stdout, "   Response:   %s\n", excerpt(a.ResponseBody, 80))
+				}
+				if a.NextRetryAt != nil {
+					fmt.Fprintf(stdout, "   Next retry: %s\n", formatRetry(a.NextRetryAt, time.Now()))
+				}
+			}
+			fmt.Fprintf(stdout, "\nShow the request sent for an attempt: sapliy webhooks inspect %s --attempt N\n", event.ID)
+		})
 	},
 }
 
-func formatTimestamp(ts string) string {
-	if ts == "" {
-		return "—"
+// printAttemptRequest prints the request sent for an attempt as raw HTTP.
+func printAttemptRequest(event *eventDetail, a *deliveryAttempt) {
+	fmt.Fprintf(stdout, "📤 Attempt %d of %s: %s in %s\n", a.Number, event.ID, a.outcome(), a.latency())
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+	req := a.Request
+	fmt.Fprintf(stdout, "%s %s\n", valueOrDash(req.Method), req.URL)
+	for _, name := range sortedKeys(req.Headers) {
+		fmt.Fprintf(stdout, "%s: %s\n", name, req.Headers[name])
 	}
-	t, err := time.Parse(time.RFC3339, ts)
-	if err != nil {
-		return ts
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, req.Body)
+	fmt.Fprintln(stdout, strings.Repeat("─", 60))
+
+	if a.ResponseBody != "" {
+		fmt.Fprintln(stdout, "Response:")
+		fmt.Fprintln(stdout, a.ResponseBody)
+	}
+	if !showSecrets {
+		fmt.Fprintln(progress(), "\nSignatures are masked; pass --show-secrets to replay the request verbatim.")
 	}
-	return t.Format("Jan 02 15:04")
+}
+
+// webhookZone returns the zone from --zone or the current zone.
+func webhookZone() (string, error) {
+	if zoneID != "" {
+		return zoneID, nil
+	}
+	if zone := viper.GetString("current_zone"); zone != "" {
+		return zone, nil
+	}
+	e := newCLIError(kindValidation, "zone ID is required")
+	e.Hint = "pass --zone or run 'sapliy zones switch <id>'"
+	return "", e
 }
 
 func truncate(s string, maxLen int) string {
@@ -241,12 +471,30 @@ func init() {
 	webhooksCmd.AddCommand(webhooksReplayFailedCmd)
 	webhooksCmd.AddCommand(webhooksInspectCmd)
 
-	webhooksListCmd.Flags().IntP("limit", "l", 20, "Number of events to fetch")
+	webhooksListCmd.Flags().Int("page-size", defaultEventPageSize, fmt.Sprintf("Number of events per page (max %d)", maxEventPageSize))
+	webhooksListCmd.Flags().IntP("limit", "l", defaultEventPageSize, "Number of events to fetch")
+	webhooksListCmd.Flags().MarkDeprecated("limit", "use --page-size instead")
+	webhooksListCmd.Flags().String("starting-after", "", "List events older than this event ID")
+	webhooksListCmd.Flags().String("ending-before", "", "List events newer than this event ID")
+	webhooksListCmd.Flags().Bool("all", false, "Fetch every page instead of only the first")
+	webhooksListCmd.Flags().String("since", "", "Only events created at or after this time (e.g. 24h, 7d, 2024-01-15, RFC 3339)")
+	webhooksListCmd.Flags().String("until", "", "Only events created at or before this time")
+	webhooksListCmd.MarkFlagsMutuallyExclusive("starting-after", "ending-before")
 	webhooksListCmd.Flags().StringP("status", "s", "", "Filter by status (pending, succeeded, failed)")
+	webhooksListCmd.Flags().StringP("type", "t", "", "Filter by event type; 'payment.*' matches every payment event")
+	webhooksListCmd.Flags().String("endpoint", "", "Filter by endpoint URL (substring match)")
+	webhooksListCmd.Flags().Int("min-attempts", 0, "Only events with at least this many delivery attempts")
 	webhooksCmd.PersistentFlags().StringVarP(&zoneID, "zone", "z", "", "Zone ID to scope the events")
 
+	webhooksInspectCmd.Flags().Int("attempt", 0, "Print the exact request sent for delivery attempt N")
+
 	webhooksReplayCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+	addIdempotencyKeyFlag(webhooksReplayCmd)
 
-	webhooksReplayFailedCmd.Flags().String("since", "24h", "Time range for failed webhooks (e.g., 1h, 24h, 7d)")
+	webhooksReplayFailedCmd.Flags().String("since", "24h", "Time range for failed webhooks (e.g., 1h, 24h, 7d, 2024-01-15, RFC 3339)")
 	webhooksReplayFailedCmd.Flags().Bool("dry-run", false, "Show what would be replayed without doing it")
+	webhooksReplayFailedCmd.Flags().Int("concurrency", defaultReplayConcurrency, "Number of replays to run in parallel")
+	webhooksReplayFailedCmd.Flags().Float64("rate", defaultReplayRate, "Maximum replays started per second (0 for no limit)")
+	webhooksReplayFailedCmd.Flags().StringP("type", "t", "", "Only replay this event type; 'payment.*' matches every payment event")
+	webhooksReplayFailedCmd.Flags().String("endpoint", "", "Only replay deliveries to this endpoint URL (substring match)")
 }
@@ -3,11 +3,9 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 
 	fintech "github.com/sapliy/fintech-sdk-go"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var zonesCmd = &cobra.Command{
@@ -18,54 +16,72 @@ var zonesCmd = &cobra.Command{
 var listZonesCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all zones in an organization",
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		orgID := viper.GetString("org_id")
-		if apiKey == "" || orgID == "" {
-			fmt.Println("Error: Not authenticated or org_id not set. Use 'sapliy auth login'.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
+		}
+		orgID, err := requireSetting("org_id")
+		if err != nil {
+			return err
 		}
 
-		client := fintech.NewClient(apiKey)
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
 		zones, err := client.Zones.List(context.Background(), orgID)
 		if err != nil {
-			fmt.Printf("Error listing zones: %v\n", err)
-			return
+			return fmt.Errorf("list zones: %w", err)
 		}
 
-		fmt.Printf("%-20s %-20s %-10s\n", "ID", "NAME", "MODE")
-		for _, z := range zones {
-			fmt.Printf("%-20s %-20s %-10s\n", z.ID, z.Name, z.Mode)
-		}
+		return printList(zones, []column[fintech.Zone]{
+			{"ID", func(z fintech.Zone) string { return z.ID }},
+			{"NAME", func(z fintech.Zone) string { return z.Name }},
+			{"MODE", func(z fintech.Zone) string { return z.Mode }},
+		}, func() {
+			fmt.Fprintf(stdout, "%-20s %-20s %-10s\n", "ID", "NAME", "MODE")
+			for _, z := range zones {
+				fmt.Fprintf(stdout, "%-20s %-20s %-10s\n", z.ID, z.Name, z.Mode)
+			}
+		})
 	},
 }
 
 var createZoneCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new zone",
-	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("api_key")
-		orgID := viper.GetString("org_id")
-		if apiKey == "" || orgID == "" {
-			fmt.Println("Error: Not authenticated or org_id not set.")
-			os.Exit(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiKey, err := requireAPIKey()
+		if err != nil {
+			return err
+		}
+		orgID, err := requireSetting("org_id")
+		if err != nil {
+			return err
 		}
 
 		name, _ := cmd.Flags().GetString("name")
 		mode, _ := cmd.Flags().GetString("mode")
 
-		client := fintech.NewClient(apiKey)
-		z, err := client.Zones.Create(context.Background(), &fintech.CreateZoneRequest{
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
+		key := commandIdempotencyKey(cmd)
+		ctx := withIdempotencyKey(context.Background(), key)
+		z, err := client.Zones.Create(ctx, &fintech.CreateZoneRequest{
 			OrgID: orgID,
 			Name:  name,
 			Mode:  mode,
 		})
 		if err != nil {
-			fmt.Printf("Error creating zone: %v\n", err)
-			return
+			return withIdempotencyKeyHint(fmt.Errorf("create zone: %w", err), key)
 		}
 
-		fmt.Printf("Zone created successfully! ID: %s, Mode: %s\n", z.ID, z.Mode)
+		return printObject(z, func() {
+			fmt.Fprintf(stdout, "Zone created successfully! ID: %s, Mode: %s\n", z.ID, z.Mode)
+		})
 	},
 }
 
@@ -73,17 +89,12 @@ var switchZoneCmd = &cobra.Command{
 	Use:   "switch [id]",
 	Short: "Switch current zone",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		viper.Set("current_zone", args[0])
-		err := viper.WriteConfig()
-		if err != nil {
-			err = viper.SafeWriteConfig()
-		}
-		if err != nil {
-			fmt.Printf("Error saving config: %v\n", err)
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := saveProfileValue("current_zone", args[0]); err != nil {
+			return fmt.Errorf("save config: %w", err)
 		}
-		fmt.Printf("Switched to zone: %s\n", args[0])
+		fmt.Fprintf(stdout, "Switched to zone: %s\n", args[0])
+		return nil
 	},
 }
 
@@ -95,5 +106,6 @@ func init() {
 
 	createZoneCmd.Flags().StringP("name", "n", "", "Name of the zone")
 	createZoneCmd.Flags().StringP("mode", "m", "test", "Mode (test/live)")
+	addIdempotencyKeyFlag(createZoneCmd)
 	createZoneCmd.MarkFlagRequired("name")
 }